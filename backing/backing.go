@@ -0,0 +1,483 @@
+// Package backing puts a Cache in front of a persistent Store, writing through to it
+// or behind it in coalesced batches.
+package backing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/graxinc/cache"
+	"github.com/graxinc/cache/clock"
+	"github.com/graxinc/errutil"
+)
+
+// A persistent key-value store, see MemoryStore.
+// Concurrent safe.
+type Store[K comparable, V any] interface {
+	// found is false for missing keys, without an error.
+	Load(ctx context.Context, k K) (_ V, found bool, _ error)
+	Store(ctx context.Context, k K, v V) error
+	// Missing keys are not an error.
+	Delete(ctx context.Context, k K) error
+}
+
+// Optionally implemented by a Store to apply write-behind batches at once,
+// rather than a Store or Delete per write.
+type BatchStore[K comparable, V any] interface {
+	Store[K, V]
+	// Applied entirely, or retried entirely on error.
+	Apply(ctx context.Context, ws []Write[K, V]) error
+}
+
+// A pending write-behind change of a key.
+type Write[K, V any] struct {
+	Key    K
+	Value  V
+	Delete bool // Otherwise a Store of Value.
+}
+
+type Mode uint8
+
+const (
+	WriteThrough Mode = iota // Set and Delete write the Store before returning.
+	WriteBehind              // Set and Delete write the Store in the background, see Options.
+)
+
+type Options[K comparable, V any] struct {
+	Cache cache.CacheOptions[K, V] // Evict and EvictReason are still called, and must not Set or Delete. Clock is also used for retries.
+	Mode  Mode
+
+	// WriteBehind, where writes of a key are coalesced until written.
+	FlushInterval time.Duration // Max delay before writing. Defaults to 1s.
+	MaxBatch      int           // Writes per batch, flushing early once this many are pending. Defaults to 100.
+	MaxRetries    int           // Retries of a failed write before dropping it. Defaults to 5.
+	RetryBackoff  time.Duration // Delay of the first retry, doubling per retry. Defaults to 100ms.
+	MaxBackoff    time.Duration // Defaults to 30s.
+	OnError       func(error)   // Called with background write errors, including dropped writes. Might be called concurrently.
+}
+
+// A cache.Cache reading from and writing to a Store.
+// Concurrent safe.
+type Cache[K comparable, V any] struct {
+	cache  *cache.Cache[K, V]
+	store  Store[K, V]
+	batch  BatchStore[K, V] // might be nil
+	behind bool
+	sizer  func(K, V) int64
+	clock  clock.Clock
+	report func(error) // might be nil
+
+	interval   time.Duration
+	maxBatch   int
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	writes atomic.Uint64 // count of Sets and Deletes, so Get can skip caching loads racing them.
+
+	mu     sync.Mutex
+	dirty  map[K]*pending[K, V] // latest write per key, until written or dropped.
+	closed bool
+
+	cacheMu sync.Mutex // orders cache writes as their pending writes, see apply.
+
+	flushMu sync.Mutex    // serializes store writes, keeping their order per key.
+	kick    chan struct{} // buffered 1, flushes early.
+	stop    chan struct{}
+	done    chan struct{} // closed once run returns.
+
+	stats stats
+}
+
+type pending[K, V any] struct {
+	w       Write[K, V]
+	seq     uint64 // from Cache.writes, differing once replaced.
+	retries int
+	retryAt time.Time
+}
+
+type stats struct {
+	loads, flushes, written, retries, dropped atomic.Int64
+}
+
+// With WriteBehind, caller must Close, writing those pending.
+func New[K comparable, V any](store Store[K, V], o Options[K, V]) *Cache[K, V] {
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = time.Second
+	}
+	if o.MaxBatch <= 0 {
+		o.MaxBatch = 100
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 5
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	if o.Cache.Clock == nil {
+		o.Cache.Clock = clock.System{}
+	}
+	sizer := o.Cache.Sizer
+	if sizer == nil {
+		sizer = func(K, V) int64 { return 1 }
+	}
+
+	c := &Cache[K, V]{
+		store:      store,
+		behind:     o.Mode == WriteBehind,
+		sizer:      sizer,
+		clock:      o.Cache.Clock,
+		report:     o.OnError,
+		interval:   o.FlushInterval,
+		maxBatch:   o.MaxBatch,
+		maxRetries: o.MaxRetries,
+		backoff:    o.RetryBackoff,
+		maxBackoff: o.MaxBackoff,
+		dirty:      make(map[K]*pending[K, V]),
+		kick:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	c.batch, _ = store.(BatchStore[K, V])
+
+	evict := o.Cache.EvictReason
+	if evict == nil {
+		e := o.Cache.Evict
+		if e == nil {
+			e = func(K, V) {}
+		}
+		evict = func(k K, v V, _ cache.Reason) { e(k, v) }
+	}
+	o.Cache.EvictReason = func(k K, v V, r cache.Reason) {
+		if r != cache.ReasonReplaced && r != cache.ReasonDeleted {
+			c.evicted(k)
+		}
+		evict(k, v, r)
+	}
+	c.cache = cache.NewCache(o.Cache)
+
+	if c.behind {
+		go c.run()
+	} else {
+		close(c.done)
+	}
+	return c
+}
+
+// The underlying cache, for reads and metrics. Writes should go through Set and Delete.
+func (c *Cache[K, V]) Cache() *cache.Cache[K, V] {
+	return c.cache
+}
+
+// From the cache, otherwise pending writes, otherwise the Store, caching found values.
+// Loads are not deduplicated, see cache.CacheOptions.Loader for that.
+func (c *Cache[K, V]) Get(ctx context.Context, k K) (_ V, found bool, _ error) {
+	if v, ok := c.cache.Get(k); ok {
+		return v, true, nil
+	}
+
+	seq := c.writes.Load()
+	if w, ok := c.pendingWrite(k); ok {
+		if w.Delete {
+			var zero V
+			return zero, false, nil
+		}
+		return w.Value, true, nil
+	}
+
+	c.stats.loads.Add(1)
+	v, found, err := c.store.Load(ctx, k)
+	if err != nil {
+		return v, false, errutil.Wrap(err)
+	}
+	if found {
+		c.cacheMu.Lock()
+		if c.writes.Load() == seq { // otherwise the load might be stale.
+			c.cache.SetIfAbsent(k, v, c.sizer(k, v))
+		}
+		c.cacheMu.Unlock()
+	}
+	return v, found, nil
+}
+
+// SetS using cache.CacheOptions.Sizer.
+func (c *Cache[K, V]) Set(ctx context.Context, k K, v V) error {
+	return c.SetS(ctx, k, v, c.sizer(k, v))
+}
+
+// Sets the cache and writes the Store, see Mode. On a WriteThrough error k is removed
+// from the cache, as the stored value is unknown. Concurrent WriteThrough Sets of a key
+// might cache a value other than the last stored.
+func (c *Cache[K, V]) SetS(ctx context.Context, k K, v V, size int64) error {
+	if c.apply(Write[K, V]{Key: k, Value: v}, size) {
+		return nil
+	}
+	if err := c.store.Store(ctx, k, v); err != nil {
+		c.cache.Delete(k)
+		return errutil.Wrap(err)
+	}
+	c.cache.SetS(k, v, size)
+	return nil
+}
+
+// Deletes from the Store and the cache, see Mode. On a WriteThrough error k is still
+// removed from the cache.
+func (c *Cache[K, V]) Delete(ctx context.Context, k K) error {
+	if c.apply(Write[K, V]{Key: k, Delete: true}, 0) {
+		return nil
+	}
+	err := c.store.Delete(ctx, k)
+
+	// after the Store, so Gets loading meanwhile don't cache k.
+	c.cacheMu.Lock()
+	c.writes.Add(1)
+	c.cache.Delete(k)
+	c.cacheMu.Unlock()
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	return nil
+}
+
+// Writes all pending, regardless of retry backoff. Failed writes remain pending
+// unless out of retries.
+func (c *Cache[K, V]) Flush(ctx context.Context) error {
+	return c.flush(ctx, true)
+}
+
+// Stops background writes and Flushes, after which writes are through.
+// Writes failing the Flush are dropped.
+func (c *Cache[K, V]) Close(ctx context.Context) error {
+	c.mu.Lock()
+	closed := c.closed
+	c.closed = true
+	c.mu.Unlock()
+
+	if !closed && c.behind {
+		close(c.stop)
+	}
+	<-c.done
+
+	err := c.Flush(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.dropped.Add(int64(len(c.dirty)))
+	clear(c.dirty)
+	return err
+}
+
+// Intended for metrics.
+func (c *Cache[K, V]) Stats() map[string]any {
+	c.mu.Lock()
+	pending := len(c.dirty)
+	c.mu.Unlock()
+
+	return map[string]any{
+		"pending": pending,
+		"loads":   c.stats.loads.Load(),
+		"flushes": c.stats.flushes.Load(),
+		"written": c.stats.written.Load(),
+		"retries": c.stats.retries.Load(),
+		"dropped": c.stats.dropped.Load(),
+	}
+}
+
+// Enqueues w and writes it to the cache, under cacheMu so the cache holds the last pending
+// write of a key. Returns false when writing through, having done neither.
+func (c *Cache[K, V]) apply(w Write[K, V], size int64) bool {
+	if !c.behind {
+		c.writes.Add(1)
+		return false
+	}
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if !c.enqueue(w) {
+		return false
+	}
+	if w.Delete {
+		c.cache.Delete(w.Key)
+	} else {
+		c.cache.SetS(w.Key, w.Value, size)
+	}
+	return true
+}
+
+// Returns false once closed, writing through.
+func (c *Cache[K, V]) enqueue(w Write[K, V]) bool {
+	seq := c.writes.Add(1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.dirty[w.Key] = &pending[K, V]{w: w, seq: seq} // replaces, restarting retries.
+	if len(c.dirty) >= c.maxBatch {
+		c.signal()
+	}
+	return true
+}
+
+func (c *Cache[K, V]) pendingWrite(k K) (Write[K, V], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.dirty[k]
+	if !ok {
+		return Write[K, V]{}, false
+	}
+	return p.w, true
+}
+
+// Flushes early when k is pending, so evicted values are not held long outside the cache.
+// Called while the cache is locked.
+func (c *Cache[K, V]) evicted(k K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.dirty[k]; ok {
+		c.signal()
+	}
+}
+
+func (c *Cache[K, V]) signal() {
+	select {
+	case c.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Cache[K, V]) run() {
+	defer close(c.done)
+
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+		case <-c.kick:
+		}
+		if err := c.flush(context.Background(), false); err != nil && c.report != nil {
+			c.report(err)
+		}
+	}
+}
+
+// Writes those pending, only those due for retry unless all.
+func (c *Cache[K, V]) flush(ctx context.Context, all bool) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	ps := c.due(all)
+	if len(ps) == 0 {
+		return nil
+	}
+	c.stats.flushes.Add(1)
+
+	var errs []error
+	for len(ps) > 0 {
+		n := min(len(ps), c.maxBatch)
+		errs = append(errs, c.write(ctx, ps[:n])...)
+		ps = ps[n:]
+	}
+	return errors.Join(errs...)
+}
+
+// Copies of those pending.
+func (c *Cache[K, V]) due(all bool) []pending[K, V] {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ps []pending[K, V]
+	for _, p := range c.dirty {
+		if all || !p.retryAt.After(now) {
+			ps = append(ps, *p)
+		}
+	}
+	return ps
+}
+
+func (c *Cache[K, V]) write(ctx context.Context, ps []pending[K, V]) []error {
+	errs := make([]error, len(ps))
+	if c.batch != nil {
+		ws := make([]Write[K, V], len(ps))
+		for i, p := range ps {
+			ws[i] = p.w
+		}
+		if err := c.batch.Apply(ctx, ws); err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+		}
+	} else {
+		for i, p := range ps {
+			if p.w.Delete {
+				errs[i] = c.store.Delete(ctx, p.w.Key)
+			} else {
+				errs[i] = c.store.Store(ctx, p.w.Key, p.w.Value)
+			}
+		}
+	}
+	return c.written(ps, errs)
+}
+
+// Removes written, scheduling retries of failures. Returns the failures, once per batch error.
+func (c *Cache[K, V]) written(ps []pending[K, V], errs []error) []error {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var failed []error
+	for i, p := range ps {
+		err := errs[i]
+		if err == nil {
+			c.stats.written.Add(1)
+		}
+
+		cur, ok := c.dirty[p.w.Key]
+		if !ok || cur.seq != p.seq {
+			continue // replaced, the newer write pending.
+		}
+		if err == nil {
+			delete(c.dirty, p.w.Key)
+			continue
+		}
+
+		if cur.retries >= c.maxRetries {
+			delete(c.dirty, p.w.Key)
+			c.stats.dropped.Add(1)
+			err = errutil.Wrapt(err, errutil.Tags{"dropped": true, "retries": cur.retries})
+		} else {
+			cur.retries++
+			cur.retryAt = now.Add(c.retryDelay(cur.retries))
+			c.stats.retries.Add(1)
+		}
+		if c.batch == nil || len(failed) == 0 {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// Of the nth retry, from 1.
+func (c *Cache[K, V]) retryDelay(n int) time.Duration {
+	d := c.backoff
+	for range n - 1 {
+		if d >= c.maxBackoff/2 {
+			return c.maxBackoff
+		}
+		d *= 2
+	}
+	return min(d, c.maxBackoff)
+}
@@ -0,0 +1,356 @@
+package backing_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graxinc/cache"
+	"github.com/graxinc/cache/backing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var errDown = errors.New("down")
+
+type storeOnly = backing.Store[string, int]
+
+func TestCache_writeThrough(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := backing.NewMemoryStore[string, int]()
+	c := backing.New[string, int](s, backing.Options[string, int]{})
+	defer c.Close(ctx)
+
+	if err := c.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	diffFatal(t, map[string]int{"a": 1}, s.Contents())
+
+	// loaded and cached.
+	if err := s.Store(ctx, "b", 2); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		v, found, err := c.Get(ctx, "b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffFatal(t, true, found)
+		diffFatal(t, 2, v)
+	}
+	diffFatal(t, int64(1), c.Stats()["loads"])
+
+	_, found, err := c.Get(ctx, "missing")
+	if err != nil || found {
+		t.Fatal(found, err)
+	}
+
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	diffFatal(t, map[string]int{"b": 2}, s.Contents())
+	diffFatal(t, 1, c.Cache().Len())
+
+	// failed set removes the cached value.
+	s.SetErr(errDown)
+	if err := c.Set(ctx, "b", 3); !errors.Is(err, errDown) {
+		t.Fatal(err)
+	}
+	if _, ok := c.Cache().Get("b"); ok {
+		t.Fatal("expected removed")
+	}
+	if _, _, err := c.Get(ctx, "b"); !errors.Is(err, errDown) {
+		t.Fatal(err)
+	}
+}
+
+func TestCache_writeThrough_deleteGet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := &deleteHookStore{MemoryStore: backing.NewMemoryStore[string, int]()}
+	c := backing.New[string, int](s, backing.Options[string, int]{})
+	defer c.Close(ctx)
+
+	if err := s.Store(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	s.beforeDelete = func() { // loads and caches before the Store deletes.
+		if _, _, err := c.Get(ctx, "a"); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, found, err := c.Get(ctx, "a")
+	if err != nil || found {
+		t.Fatal(found, err)
+	}
+}
+
+func TestCache_writeBehind(t *testing.T) {
+	t.Parallel()
+
+	do := func(t *testing.T, batched bool) {
+		t.Parallel()
+
+		ctx := context.Background()
+		s := backing.NewMemoryStore[string, int]()
+		var store backing.Store[string, int] = s
+		if !batched {
+			store = struct{ storeOnly }{s} // hides Apply.
+		}
+		c := backing.New(store, backing.Options[string, int]{Mode: backing.WriteBehind, FlushInterval: time.Hour})
+
+		if err := s.Store(ctx, "d", 4); err != nil {
+			t.Fatal(err)
+		}
+		for i := range 3 {
+			if err := c.Set(ctx, "a", i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.Set(ctx, "b", 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Delete(ctx, "d"); err != nil {
+			t.Fatal(err)
+		}
+		diffFatal(t, map[string]int{"d": 4}, s.Contents())
+		diffFatal(t, 3, c.Stats()["pending"])
+
+		// pending rather than the store.
+		c.Cache().Delete("a")
+		v, found, err := c.Get(ctx, "a")
+		if err != nil || !found {
+			t.Fatal(found, err)
+		}
+		diffFatal(t, 2, v)
+		if _, found, _ := c.Get(ctx, "d"); found {
+			t.Fatal("expected pending delete")
+		}
+		diffFatal(t, int64(0), c.Stats()["loads"])
+
+		if err := c.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+		diffFatal(t, map[string]int{"a": 2, "b": 2}, s.Contents())
+		diffFatal(t, int64(3), c.Stats()["written"])
+
+		want := map[string]any{"loads": int64(0), "stores": int64(1), "deletes": int64(0), "batches": int64(1)}
+		if !batched {
+			want = map[string]any{"loads": int64(0), "stores": int64(3), "deletes": int64(1), "batches": int64(0)}
+		}
+		diffFatal(t, want, s.Stats())
+
+		// Close flushes, then through.
+		if err := c.Set(ctx, "c", 3); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Set(ctx, "e", 5); err != nil {
+			t.Fatal(err)
+		}
+		diffFatal(t, map[string]int{"a": 2, "b": 2, "c": 3, "e": 5}, s.Contents())
+	}
+
+	t.Run("batched", func(t *testing.T) { do(t, true) })
+	t.Run("unbatched", func(t *testing.T) { do(t, false) })
+}
+
+func TestCache_writeBehind_concurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := backing.NewMemoryStore[string, int]()
+	c := backing.New[string, int](s, backing.Options[string, int]{
+		Cache: cache.CacheOptions[string, int]{
+			Admit: func(_ string, v int, _ int64) bool {
+				time.Sleep(time.Duration(v) * 10 * time.Microsecond) // widens races between Sets.
+				return true
+			},
+		},
+		Mode:          backing.WriteBehind,
+		FlushInterval: time.Hour,
+	})
+	defer c.Close(ctx)
+
+	for range 100 {
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var err error
+				if i%4 == 0 {
+					err = c.Delete(ctx, "k")
+				} else {
+					err = c.Set(ctx, "k", i)
+				}
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		if err := c.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		// the cache holds what was stored.
+		stored, storedOK := s.Contents()["k"]
+		cached, cachedOK := c.Cache().Peek("k")
+		if storedOK != cachedOK || stored != cached {
+			t.Fatal(stored, storedOK, cached, cachedOK)
+		}
+	}
+}
+
+func TestCache_writeBehind_background(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// interval
+	s := backing.NewMemoryStore[string, int]()
+	c := backing.New[string, int](s, backing.Options[string, int]{Mode: backing.WriteBehind, FlushInterval: time.Millisecond})
+	defer c.Close(ctx)
+	if err := c.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(s.Contents()) == 1 })
+
+	// MaxBatch
+	s2 := backing.NewMemoryStore[string, int]()
+	c2 := backing.New[string, int](s2, backing.Options[string, int]{Mode: backing.WriteBehind, FlushInterval: time.Hour, MaxBatch: 2})
+	defer c2.Close(ctx)
+	for _, k := range []string{"a", "b"} {
+		if err := c2.Set(ctx, k, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, func() bool { return len(s2.Contents()) == 2 })
+
+	// eviction
+	s3 := backing.NewMemoryStore[string, int]()
+	var mu sync.Mutex
+	var evicted []string
+	c3 := backing.New[string, int](s3, backing.Options[string, int]{
+		Cache: cache.CacheOptions[string, int]{
+			Capacity: 2,
+			Evict: func(k string, _ int) {
+				mu.Lock()
+				defer mu.Unlock()
+				evicted = append(evicted, k)
+			},
+		},
+		Mode:          backing.WriteBehind,
+		FlushInterval: time.Hour,
+	})
+	defer c3.Close(ctx)
+	for _, k := range []string{"a", "b", "c"} {
+		if err := c3.Set(ctx, k, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, func() bool { return len(s3.Contents()) == 3 })
+	mu.Lock()
+	diffFatal(t, 1, len(evicted))
+	mu.Unlock()
+}
+
+func TestCache_writeBehind_retries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := backing.NewMemoryStore[string, int]()
+	s.SetErr(errDown)
+
+	var mu sync.Mutex
+	var reported []error
+	c := backing.New[string, int](s, backing.Options[string, int]{
+		Mode:          backing.WriteBehind,
+		FlushInterval: time.Hour,
+		MaxRetries:    2,
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, err)
+		},
+	})
+	defer c.Close(ctx)
+
+	if err := c.Set(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := c.Flush(ctx); !errors.Is(err, errDown) {
+			t.Fatal(err)
+		}
+		diffFatal(t, 1, c.Stats()["pending"])
+	}
+	if err := c.Flush(ctx); !errors.Is(err, errDown) {
+		t.Fatal(err)
+	}
+	diffFatal(t, 0, c.Stats()["pending"])
+	diffFatal(t, int64(2), c.Stats()["retries"])
+	diffFatal(t, int64(1), c.Stats()["dropped"])
+
+	// retried in the background after the backoff.
+	c2 := backing.New[string, int](s, backing.Options[string, int]{
+		Mode:          backing.WriteBehind,
+		FlushInterval: time.Millisecond,
+		RetryBackoff:  time.Millisecond,
+	})
+	defer c2.Close(ctx)
+	if err := c2.Set(ctx, "b", 2); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return c2.Stats()["retries"].(int64) > 0 })
+	s.SetErr(nil)
+	waitFor(t, func() bool { return len(s.Contents()) == 1 })
+	diffFatal(t, int64(0), c2.Stats()["dropped"])
+
+	mu.Lock()
+	diffFatal(t, 0, len(reported)) // Flush errors are returned.
+	mu.Unlock()
+}
+
+// Runs beforeDelete before each Delete.
+type deleteHookStore struct {
+	*backing.MemoryStore[string, int]
+	beforeDelete func()
+}
+
+func (s *deleteHookStore) Delete(ctx context.Context, k string) error {
+	if s.beforeDelete != nil {
+		s.beforeDelete()
+	}
+	return s.MemoryStore.Delete(ctx, k)
+}
+
+func waitFor(t testing.TB, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func diffFatal(t testing.TB, want, got any, opts ...cmp.Option) {
+	t.Helper()
+	if d := cmp.Diff(want, got, opts...); d != "" {
+		t.Fatalf("(-want +got):\n%v", d)
+	}
+}
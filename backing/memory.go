@@ -0,0 +1,97 @@
+package backing
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// A Store and BatchStore in memory, intended for tests.
+// Concurrent safe.
+type MemoryStore[K comparable, V any] struct {
+	mu     sync.Mutex
+	m      map[K]V
+	err    error // might be nil
+	counts struct{ loads, stores, deletes, batches int64 }
+}
+
+func NewMemoryStore[K comparable, V any]() *MemoryStore[K, V] {
+	return &MemoryStore[K, V]{m: make(map[K]V)}
+}
+
+func (s *MemoryStore[K, V]) Load(_ context.Context, k K) (_ V, found bool, _ error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts.loads++
+	if s.err != nil {
+		var zero V
+		return zero, false, s.err
+	}
+	v, ok := s.m[k]
+	return v, ok, nil
+}
+
+func (s *MemoryStore[K, V]) Store(_ context.Context, k K, v V) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts.stores++
+	if s.err != nil {
+		return s.err
+	}
+	s.m[k] = v
+	return nil
+}
+
+func (s *MemoryStore[K, V]) Delete(_ context.Context, k K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts.deletes++
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.m, k)
+	return nil
+}
+
+func (s *MemoryStore[K, V]) Apply(_ context.Context, ws []Write[K, V]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts.batches++
+	if s.err != nil {
+		return s.err
+	}
+	for _, w := range ws {
+		if w.Delete {
+			delete(s.m, w.Key)
+		} else {
+			s.m[w.Key] = w.Value
+		}
+	}
+	return nil
+}
+
+// Fails all following calls with err, until nil.
+func (s *MemoryStore[K, V]) SetErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// A copy of the contents.
+func (s *MemoryStore[K, V]) Contents() map[K]V {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.m)
+}
+
+// Counts of calls, including failed.
+func (s *MemoryStore[K, V]) Stats() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]any{
+		"loads":   s.counts.loads,
+		"stores":  s.counts.stores,
+		"deletes": s.counts.deletes,
+		"batches": s.counts.batches,
+	}
+}
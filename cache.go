@@ -3,29 +3,132 @@ package cache
 import (
 	"iter"
 	"math"
+	"math/rand/v2"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unsafe"
 
+	"github.com/graxinc/cache/clock"
 	"github.com/graxinc/cache/maps"
 	"github.com/graxinc/cache/policy"
 	"github.com/graxinc/errutil"
 )
 
 type CacheValue[V any] struct {
-	expire uint32 // seconds since expirationEpoch
-	size   uint32 // store so it doesn't change before applying to Cache.size.
-	v      V
+	meta atomic.Uint64 // valueMeta, size stored so it doesn't change before applying to Cache.size.
+	gen  uint32        // Cache generation set in, older are cleared. See Cache.ClearFast.
+	v    V
+}
+
+// Why a value left the cache.
+type Reason uint8
+
+const (
+	ReasonCapacity    Reason = iota // Evicted by the policy, including Evict calls.
+	ReasonReplaced                  // Replaced by a Set.
+	ReasonRejected                  // Not admitted by a Set, see CacheOptions.Admit and EvictSkip.
+	ReasonCleared                   // Removed by Clear.
+	ReasonExpired                   // Removed by RemoveExpired.
+	ReasonDeleted                   // Removed by Delete or SetAbsent.
+	ReasonInvalidated               // Removed by InvalidateTag.
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonRejected:
+		return "rejected"
+	case ReasonCleared:
+		return "cleared"
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonInvalidated:
+		return "invalidated"
+	default:
+		return "unknown"
+	}
 }
 
 type CacheOptions[K, V any] struct {
-	Expiration    time.Duration // Defaults to forever.
-	Evict         func(K, V)    // Might be called concurrently.
-	EvictSkip     func(K, V) bool
-	Capacity      int64                              // Defaults to 100.
-	RLock         bool                               // Whether to use an RLock when possible. Defaults to false.
-	MapCreator    func() maps.Map[K, *CacheValue[V]] // defaults to maps.Sync.
-	PolicyCreator func() policy.Policy[K]            // defaults to policy.NewARC.
+	Expiration     time.Duration      // Millisecond resolution. Defaults to forever.
+	Clock          clock.Clock        // For expiration. Defaults to clock.System, clock.Shared avoids reading the time per Get.
+	Evict          func(K, V)         // Might be called concurrently.
+	EvictReason    func(K, V, Reason) // Like Evict with the Reason, used instead when set. Might be called concurrently.
+	EvictSkip      func(K, V) bool
+	EvictSkipLimit int                                // Max values checked with EvictSkip per eviction. Defaults to unlimited.
+	Capacity       int64                              // Max total size of values. Defaults to 100.
+	MaxEntries     int64                              // Max count of values, in addition to Capacity. Defaults to unlimited.
+	RLock          bool                               // Whether to use an RLock when possible. Defaults to false.
+	MapCreator     func() maps.Map[K, *CacheValue[V]] // defaults to maps.Sync.
+	PolicyCreator  func() policy.Policy[K]            // defaults to policy.NewARC.
+	ExternalSize   func() int64                       // Size held outside the cache that counts against Capacity. Might be called concurrently.
+	Sizer          func(K, V) int64                   // Size used by Set, see the sizer package. Defaults to 1. Might be called concurrently.
+	EntryOverhead  bool                               // Adds EntryOverhead to each value size.
+
+	// Values not admitted by a Set are evicted with ReasonRejected. Sizes include EntryOverhead.
+	Admit            func(K, V, int64) bool // Might be called concurrently.
+	AdmitMaxSize     int64                  // Rejects larger values. Defaults to unlimited.
+	AdmitMaxFraction float64                // Rejects values larger than this fraction of Capacity. Defaults to unlimited.
+
+	// Expiration restarts on each Get or Touch, rather than only on Set.
+	ExpireAfterAccess bool
+	MaxLifetime       time.Duration // Caps ExpireAfterAccess from the Set. Millisecond resolution. Defaults to unlimited.
+
+	// Moves each expiration earlier by a random amount up to the larger of these, so values
+	// set together do not expire together. Capped below Expiration.
+	ExpirationJitter         time.Duration  // Millisecond resolution.
+	ExpirationJitterFraction float64        // Of Expiration, 0-1.
+	JitterSource             func() float64 // Returns [0,1). Defaults to math/rand/v2. Might be called concurrently.
+
+	// Loads missing values for Load, see SetAbsent for errors. Might be called concurrently, though once per key at a time.
+	Loader func(K) (V, error)
+
+	// Enables absent entries (see SetAbsent), held apart from values and not counting against Capacity.
+	NegativeExpiration time.Duration // Millisecond resolution. Defaults to no absent entries.
+	NegativeCapacity   int64         // Max count of absent entries. Defaults to 100.
+
+	EventBuffer int // Unreceived events held per Subscribe before dropping. Defaults to 256.
+
+	MaxPinnedFraction float64 // Max fraction of Capacity pinned, see Pin. Defaults to 0.5.
+
+	// Priority classes of SetP, from 0 to Priorities-1, each a policy from PolicyCreator.
+	// Evictions favor lower classes, see policy.NewPriority. Set uses class 0.
+	Priorities      int       // Defaults to 1, a single policy.
+	PriorityWeights []float64 // Per class. Defaults to 2^class.
+
+	// Indexes expirations in a timing wheel so RemoveExpired only visits expired values,
+	// rather than scanning all. Costs a short lock per Set and removal.
+	ExpirationIndex bool
+}
+
+// Of a Set, see setS.
+type setOptions struct {
+	tags     []string
+	pin      bool
+	priority int
+}
+
+// Adds to and moves between priority classes, see policy.Priority.
+type classer[K any] interface {
+	AddClass(K, int) bool
+	SetClass(K, int) bool
+	Class(K) (int, bool)
+}
+
+// A key with the value it had, see Cache.remove.
+type entry[K, V any] struct {
+	k K
+	v *CacheValue[V]
+}
+
+func (v *CacheValue[V]) loadMeta() valueMeta {
+	return valueMeta(v.meta.Load())
 }
 
 type locker interface {
@@ -48,25 +151,65 @@ type Cache[K, V any] struct {
 	// immutable
 	zero            V
 	policyMu        locker
-	expiration      uint32
+	expiration      int64 // milliseconds
 	expirationEpoch time.Time
+	clock           clock.Clock
+	expireAccess    bool
+	jitter          int64 // milliseconds, 0 for none.
+	jitterSource    func() float64
+	maxLifetime     int64                           // milliseconds, 0 for unlimited.
+	limits          maps.Map[*CacheValue[V], int64] // expire caps as in valueMeta, nil without maxLifetime.
 	evictBool       atomic.Bool
-	evict           func(K, V)
+	evict           func(K, V, Reason)
 	evictSkip       func(K, V) bool // might be nil
+	externalSize    func() int64    // might be nil
+	evictSkipLimit  int
+	sizer           func(K, V) int64 // might be nil
+	overhead        int64
+	admit           func(K, V, int64) bool // might be nil
+	admitMaxSize    int64
+	admitMaxFrac    float64
 	items           maps.Map[K, *CacheValue[V]]
+	absentMu        sync.Mutex // serializes SetIfAbsent when items is not a maps.AbsentAdder.
 	policy          policy.Policy[K]
+	policyRemove    func(K) bool // policy.Remove of policy.
+	classes         classer[K]   // the policy with priority classes, might be nil.
+	wheel           *wheel[K, V] // might be nil
+	wheelMu         sync.Mutex
+	loader          func(K) (V, error) // might be nil
+	loads           loadMap[K, V]
+	negative        *Cache[K, error] // might be nil
+	tags            tagIndex[K, V]
+	subs            subscribers[K]
+	eventBuffer     int
+	maxPinnedFrac   float64
+	pinned          map[*CacheValue[V]]pinnedKey[K] // removed from the policy, see Pin. Under policyMu.
+	pinnedSize      atomic.Int64
+	pinnedLen       atomic.Int64 // len(pinned) plus pins checking, see replaced.
+
+	gen          atomic.Uint32
+	reclaimMu    sync.Mutex
+	reclaimDone  chan struct{} // nil when not reclaiming.
+	reclaimAgain bool          // another ClearFast while reclaiming.
+
+	cap        atomic.Int64
+	maxEntries atomic.Int64 // <= 0 for unlimited.
+	size       atomic.Int64
+	length     atomic.Int64
 
-	cap    atomic.Int64
-	size   atomic.Int64
-	length atomic.Int64
+	evictSkipGiveUps atomic.Int64 // evictions where all checked were skipped.
 }
 
 func NewCache[K comparable, V any](o CacheOptions[K, V]) *Cache[K, V] {
 	if o.Capacity <= 0 {
 		o.Capacity = 100
 	}
-	if o.Evict == nil {
-		o.Evict = func(K, V) {}
+	if o.EvictReason == nil {
+		evict := o.Evict
+		if evict == nil {
+			evict = func(K, V) {}
+		}
+		o.EvictReason = func(k K, v V, _ Reason) { evict(k, v) }
 	}
 	var policyMu locker
 	if o.RLock {
@@ -75,13 +218,31 @@ func NewCache[K comparable, V any](o CacheOptions[K, V]) *Cache[K, V] {
 		policyMu = &mutexLocker{}
 	}
 
-	var expiration uint32
+	var expiration int64
 	if o.Expiration > 0 {
-		secs := o.Expiration / time.Second
-		if secs > math.MaxUint32 {
+		ms := o.Expiration / time.Millisecond
+		if ms > metaExpireMax {
 			expiration = 0 // forever
 		} else {
-			expiration = max(1, uint32(secs))
+			expiration = max(1, int64(ms))
+		}
+	}
+	if o.Clock == nil {
+		o.Clock = clock.System{}
+	}
+	var jitter int64
+	if expiration > 0 {
+		j := max(int64(o.ExpirationJitter/time.Millisecond), int64(o.ExpirationJitterFraction*float64(expiration)))
+		jitter = min(max(j, 0), expiration-1)
+	}
+	if o.JitterSource == nil {
+		o.JitterSource = rand.Float64
+	}
+
+	var maxLifetime int64
+	if expiration > 0 && o.ExpireAfterAccess && o.MaxLifetime > 0 {
+		if ms := o.MaxLifetime / time.Millisecond; ms <= metaExpireMax { // otherwise unlimited.
+			maxLifetime = max(1, int64(ms))
 		}
 	}
 
@@ -92,16 +253,74 @@ func NewCache[K comparable, V any](o CacheOptions[K, V]) *Cache[K, V] {
 		o.PolicyCreator = func() policy.Policy[K] { return policy.NewARC[K]() }
 	}
 
+	var pol policy.Policy[K]
+	var classes classer[K]
+	if o.Priorities > 1 {
+		p := policy.NewPriority(o.Priorities, o.PriorityWeights, o.PolicyCreator)
+		pol, classes = p, p
+	} else {
+		pol = o.PolicyCreator()
+	}
+
+	var overhead int64
+	if o.EntryOverhead {
+		overhead = EntryOverhead[K, V]()
+	}
+
 	c := &Cache[K, V]{
 		expiration:      expiration,
-		expirationEpoch: time.Now(),
-		evict:           o.Evict,
+		expirationEpoch: o.Clock.Now(),
+		clock:           o.Clock,
+		expireAccess:    expiration > 0 && o.ExpireAfterAccess,
+		maxLifetime:     maxLifetime,
+		jitter:          jitter,
+		jitterSource:    o.JitterSource,
+		evict:           o.EvictReason,
 		evictSkip:       o.EvictSkip,
+		evictSkipLimit:  o.EvictSkipLimit,
+		externalSize:    o.ExternalSize,
+		sizer:           o.Sizer,
+		overhead:        overhead,
+		admit:           o.Admit,
+		admitMaxSize:    o.AdmitMaxSize,
+		admitMaxFrac:    o.AdmitMaxFraction,
 		items:           o.MapCreator(),
-		policy:          o.PolicyCreator(),
+		policy:          pol,
+		policyRemove:    func(k K) bool { return policy.Remove(pol, k) },
+		classes:         classes,
 		policyMu:        policyMu,
 	}
+	if maxLifetime > 0 {
+		c.limits = &maps.Sync[*CacheValue[V], int64]{}
+	}
+	if expiration > 0 && o.ExpirationIndex {
+		c.wheel = &wheel[K, V]{}
+	}
+	c.eventBuffer = o.EventBuffer
+	if c.eventBuffer <= 0 {
+		c.eventBuffer = 256
+	}
+	c.maxPinnedFrac = o.MaxPinnedFraction
+	if c.maxPinnedFrac <= 0 {
+		c.maxPinnedFrac = 0.5
+	}
+	c.pinned = make(map[*CacheValue[V]]pinnedKey[K])
+	if o.Loader != nil {
+		c.loader = o.Loader
+		c.loads = &maps.Sync[K, *loadCall[V]]{}
+	}
+	if o.NegativeExpiration > 0 {
+		if o.NegativeCapacity <= 0 {
+			o.NegativeCapacity = 100
+		}
+		c.negative = NewCache(CacheOptions[K, error]{
+			Expiration: o.NegativeExpiration,
+			Clock:      o.Clock,
+			Capacity:   o.NegativeCapacity,
+		})
+	}
 	c.cap.Store(o.Capacity)
+	c.maxEntries.Store(max(0, o.MaxEntries))
 	return c
 }
 
@@ -121,43 +340,254 @@ func (a *Cache[K, V]) Promote(k K) {
 	} // fast path for high contention, that do not promote.
 }
 
-// Promotes.
+// Promotes. Absent entries are misses, see Lookup.
 func (a *Cache[K, V]) Get(k K) (_ V, ok bool) {
 	v, ok := a.get(k)
 	if !ok {
 		return a.zero, false
 	}
 
+	a.touch(v)
 	a.Promote(k)
 
 	return v.v, true
 }
 
-// Alias for SetS(k,v,1).
+// Restarts the expiration with CacheOptions.ExpireAfterAccess, returning whether present.
+// Does not Promote.
+func (a *Cache[K, V]) Touch(k K) bool {
+	v, ok := a.get(k)
+	if !ok {
+		return false
+	}
+	a.touch(v)
+	return true
+}
+
+// Lock free, a lost race keeps the other touch.
+func (a *Cache[K, V]) touch(v *CacheValue[V]) {
+	if !a.expireAccess {
+		return
+	}
+	m := v.loadMeta()
+	expire := a.expire()
+	if a.limits != nil {
+		if limit, ok := a.limits.Get(v); ok {
+			expire = min(expire, limit)
+		}
+	}
+	if expire <= m.expire() {
+		return
+	}
+	v.meta.CompareAndSwap(uint64(m), uint64(newValueMeta(expire, m.size())))
+}
+
+// SetS using CacheOptions.Sizer.
 func (a *Cache[K, V]) Set(k K, v V) {
-	a.SetS(k, v, 1)
+	a.SetS(k, v, a.sizeOf(k, v))
 }
 
 // Replaces existing values, which are evicted.
 // A min size of 1 will be used. Set item always comes out of evict.
-func (a *Cache[K, V]) SetS(k K, v V, size uint32) {
+func (a *Cache[K, V]) SetS(k K, v V, size int64) {
+	a.setS(k, v, size, setOptions{})
+}
+
+// Like SetS, tagging the value for InvalidateTag.
+func (a *Cache[K, V]) SetST(k K, v V, size int64, tags ...string) {
+	a.setS(k, v, size, setOptions{tags: tags})
+}
+
+// Like SetS, in a priority class from 0 (evicted first) to CacheOptions.Priorities-1.
+// priority is clamped to the classes.
+func (a *Cache[K, V]) SetP(k K, v V, size int64, priority int) {
+	a.setS(k, v, size, setOptions{priority: priority})
+}
+
+func (a *Cache[K, V]) setS(k K, v V, size int64, o setOptions) (pinned bool) {
 	// items.Add replaces, and we return if exists. That ensures only one
 	// caller will get past items.Add until items.Delete (after eviction),
 	// keeping the set of keys between policy and items consistent.
 
-	if a.evictSkip != nil && a.evicts() {
-		a.evict(k, v)
-		return
+	a.removeAbsent(k)
+
+	size = a.entrySize(size)
+	if a.reject(k, v, size) {
+		a.removed(k, v, ReasonRejected)
+		return false
+	}
+
+	av := a.newValue(v, size)
+	a.tags.add(k, av, o.tags) // before visible, so removals find it.
+	a.schedule(k, av)
+	p, ok := a.items.Add(k, av)
+	return a.added(k, av, p, ok, o)
+}
+
+// Removes values tagged by SetST, evicting with ReasonInvalidated. Returns the count removed.
+func (a *Cache[K, V]) InvalidateTag(tag string) (removed int) {
+	for _, e := range a.tags.entries(tag) {
+		if !a.cleared(e.v) && a.remove(e.k, e.v, ReasonInvalidated) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// Evicts with ReasonDeleted, returning whether removed.
+func (a *Cache[K, V]) Delete(k K) bool {
+	return a.delete(k, ReasonDeleted)
+}
+
+// Deletes values matching f, including expired, returning the count removed.
+// f is called while blocking, and must not use the Cache.
+func (a *Cache[K, V]) DeleteFunc(f func(K, V) bool) (removed int) {
+	for _, e := range a.matching(f) {
+		if a.remove(e.k, e.v, ReasonDeleted) {
+			removed++
+		}
+	}
+	return removed
+}
+
+func (a *Cache[K, V]) matching(f func(K, V) bool) []entry[K, V] {
+	a.policyMu.RLock()
+	defer a.policyMu.RUnlock()
+
+	var es []entry[K, V]
+	for k := range a.keys() {
+		v := a.panicGet(k)
+		if a.cleared(v) {
+			continue
+		}
+		if f(k, v.v) {
+			es = append(es, entry[K, V]{k, v})
+		}
+	}
+	return es
+}
+
+// Like SetS, except an existing unexpired value is kept and returned with !inserted.
+// Expired values are replaced, concurrent callers might then each insert.
+// !ok when v was rejected (see CacheOptions.Admit and EvictSkip), even if set meanwhile.
+func (a *Cache[K, V]) SetIfAbsent(k K, v V, size int64) (_ V, inserted, ok bool) {
+	if e, exists := a.get(k); exists {
+		return e.v, false, true
 	}
 
+	size = a.entrySize(size)
+	if a.reject(k, v, size) {
+		a.removed(k, v, ReasonRejected)
+		return a.zero, false, false
+	}
+
+	av := a.newValue(v, size)
+	a.schedule(k, av) // before visible, so removals find it.
+	p, exists, replaced := a.addIfAbsent(k, av)
+	if exists && !replaced {
+		if a.live(p) {
+			a.forget(av)
+			return p.v, false, true
+		}
+		p, exists = a.items.Add(k, av)
+	}
+	a.added(k, av, p, exists, setOptions{})
+	a.removeAbsent(k)
+	return v, true, true
+}
+
+// items.AddIfAbsent, otherwise a Get then Add under absentMu, atomic only against other
+// SetIfAbsents. replaced when the Add replaced p, set concurrently.
+func (a *Cache[K, V]) addIfAbsent(k K, v *CacheValue[V]) (p *CacheValue[V], exists, replaced bool) {
+	if m, ok := a.items.(maps.AbsentAdder[K, *CacheValue[V]]); ok {
+		p, exists = m.AddIfAbsent(k, v)
+		return p, exists, false
+	}
+
+	a.absentMu.Lock()
+	defer a.absentMu.Unlock()
+	if p, ok := a.items.Get(k); ok {
+		return p, true, false
+	}
+	p, exists = a.items.Add(k, v)
+	return p, exists, exists
+}
+
+// The size counted for a value Set with size, including the min of 1,
+// CacheOptions.EntryOverhead and rounding of large sizes.
+func (a *Cache[K, V]) EntrySize(size int64) int64 {
+	return packSize(a.entrySize(size)).int64()
+}
+
+// Includes the min and overhead.
+func (a *Cache[K, V]) entrySize(size int64) int64 {
 	size = max(1, size)
+	return size + min(a.overhead, math.MaxInt64-size) // saturating
+}
 
-	av := &CacheValue[V]{expire: a.expire(), size: size, v: v}
+func (a *Cache[K, V]) reject(k K, v V, size int64) bool {
+	if a.admitMaxSize > 0 && size > a.admitMaxSize {
+		return true
+	}
+	if a.admitMaxFrac > 0 && float64(size) > a.admitMaxFrac*float64(a.cap.Load()) {
+		return true
+	}
+	if a.admit != nil && !a.admit(k, v, size) {
+		return true
+	}
+	return a.evictSkip != nil && a.evicts()
+}
 
-	if p, ok := a.items.Add(k, av); ok {
-		a.size.Add(int64(size) - int64(p.size)) // remove+add
-		a.evict(k, p.v)
-		return
+// size from entrySize.
+func (a *Cache[K, V]) newValue(v V, size int64) *CacheValue[V] {
+	expire := a.expire()
+
+	var limit int64
+	if a.maxLifetime > 0 {
+		limit = a.msAfterExpireEpoch() + a.maxLifetime
+		expire = min(expire, limit)
+	}
+
+	av := &CacheValue[V]{gen: a.gen.Load(), v: v}
+	av.meta.Store(uint64(newValueMeta(expire, packSize(size))))
+	if limit > 0 {
+		a.limits.Add(av, limit) // apart from the value, so CacheValue is no bigger without MaxLifetime.
+	}
+	return av
+}
+
+// v left the cache or was never added, dropping what is held apart from it.
+func (a *Cache[K, V]) forget(v *CacheValue[V]) {
+	a.tags.remove(v)
+	if a.limits != nil {
+		a.limits.Delete(v)
+	}
+	if a.wheel != nil {
+		a.wheelMu.Lock()
+		a.wheel.remove(v)
+		a.wheelMu.Unlock()
+	}
+}
+
+func (a *Cache[K, V]) sizeOf(k K, v V) int64 {
+	if a.sizer == nil {
+		return 1
+	}
+	return a.sizer(k, v)
+}
+
+// av was added to items, replacing p when exists. Pins when o.pin, or when p was pinned.
+func (a *Cache[K, V]) added(k K, av, p *CacheValue[V], exists bool, o setOptions) (pinned bool) {
+	if exists {
+		a.forget(p)
+		pinned = a.replaced(k, av, p, o)
+		a.size.Add(av.loadMeta().size().int64() - p.loadMeta().size().int64()) // remove+add
+		r := a.reason(p, ReasonReplaced)
+		a.removed(k, p.v, r)
+		if r != ReasonReplaced {
+			a.subs.notify(Event[K]{Kind: EventSet, Key: k}) // a cleared value was replaced.
+		}
+		return pinned
 	}
 
 	if a.evictSkip == nil {
@@ -165,8 +595,10 @@ func (a *Cache[K, V]) SetS(k K, v V, size uint32) {
 	}
 
 	a.length.Add(1)
-	a.size.Add(int64(size))
-	a.panicPolicyAdd(k)
+	a.size.Add(av.loadMeta().size().int64())
+	pinned = a.panicPolicyAdd(k, av, o)
+	a.subs.notify(Event[K]{Kind: EventSet, Key: k})
+	return pinned
 }
 
 func (a *Cache[K, V]) Evict() (noSpace bool) {
@@ -179,36 +611,134 @@ func (a *Cache[K, V]) evictSingle() (noSpace bool) {
 		return true
 	}
 	v := a.panicDelete(k)
+	a.forget(v)
 
 	a.length.Add(-1)
-	a.size.Add(-int64(v.size))
-	a.evict(k, v.v)
+	a.size.Add(-v.loadMeta().size().int64())
+	a.removed(k, v.v, a.reason(v, ReasonCapacity))
 	return false
 }
 
 func (a *Cache[K, V]) evicts() (noSpace bool) {
 	if a.evictBool.Swap(true) { // old was true, other already doing
-		return a.size.Load() >= a.cap.Load()
+		return a.full()
 	}
 	defer a.evictBool.Store(false)
 
-	for a.size.Load() >= a.cap.Load() {
+	for a.full() {
 		if a.evictSingle() {
+			if a.expireAllPinned() > 0 {
+				continue
+			}
 			return true
 		}
 	}
 	return false
 }
 
-// Results ordered by hot->cold. Will block.
+func (a *Cache[K, V]) full() bool {
+	size := a.size.Load()
+	if a.externalSize != nil {
+		size += a.externalSize()
+	}
+	if size >= a.cap.Load() {
+		return true
+	}
+	m := a.maxEntries.Load()
+	return m > 0 && a.length.Load() >= m
+}
+
+// Removes expired values, evicting with ReasonExpired. Returns the count removed.
+// Values otherwise remain until evicted or replaced, though not returned. Will block.
+func (a *Cache[K, V]) RemoveExpired() (removed int) {
+	if a.expiration <= 0 {
+		return 0
+	}
+	for _, e := range a.dueExpirations() {
+		if a.cleared(e.v) {
+			continue // reclaiming.
+		}
+		if !a.expired(e.v.loadMeta().expire()) { // touched since
+			a.reschedule(e.k, e.v)
+			continue
+		}
+		if a.remove(e.k, e.v, ReasonExpired) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// From the wheel when indexed, otherwise scanning the policy.
+func (a *Cache[K, V]) dueExpirations() []entry[K, V] {
+	if a.wheel != nil {
+		a.wheelMu.Lock()
+		defer a.wheelMu.Unlock()
+		return a.wheel.advance(a.msAfterExpireEpoch())
+	}
+
+	a.policyMu.RLock()
+	defer a.policyMu.RUnlock()
+
+	var due []entry[K, V]
+	for k := range a.keys() {
+		v := a.panicGet(k)
+		if a.expired(v.loadMeta().expire()) && !a.cleared(v) {
+			due = append(due, entry[K, V]{k, v})
+		}
+	}
+	return due
+}
+
+func (a *Cache[K, V]) schedule(k K, v *CacheValue[V]) {
+	if a.wheel == nil {
+		return
+	}
+	a.wheelMu.Lock()
+	defer a.wheelMu.Unlock()
+	a.wheel.schedule(k, v, v.loadMeta().expire())
+}
+
+// Like schedule unless v was since removed, which forget would have missed.
+func (a *Cache[K, V]) reschedule(k K, v *CacheValue[V]) {
+	a.wheelMu.Lock()
+	defer a.wheelMu.Unlock()
+	if cur, ok := a.items.Get(k); ok && cur == v {
+		a.wheel.schedule(k, v, v.loadMeta().expire())
+	}
+}
+
+func (a *Cache[K, V]) delete(k K, reason Reason) bool {
+	v, ok := a.items.Get(k)
+	return ok && !a.cleared(v) && a.remove(k, v, reason)
+}
+
+// Removes k if still v, returning whether removed.
+func (a *Cache[K, V]) remove(k K, v *CacheValue[V], reason Reason) bool {
+	a.policyMu.Lock()
+	if cur, ok := a.items.Get(k); !ok || cur != v || !(a.unpin(v) || a.policyRemove(k)) {
+		a.policyMu.Unlock()
+		return false // replaced, removed, or not yet in the policy.
+	}
+	v = a.panicDelete(k)
+	a.policyMu.Unlock()
+	a.forget(v)
+
+	a.length.Add(-1)
+	a.size.Add(-v.loadMeta().size().int64())
+	a.removed(k, v.v, a.reason(v, reason))
+	return true
+}
+
+// Results ordered by pinned, then hot->cold. Will block.
 func (a *Cache[K, V]) All() iter.Seq2[K, V] {
 	return func(yield func(K, V) bool) {
 		a.policyMu.RLock()
 		defer a.policyMu.RUnlock()
 
-		for k := range a.policy.Values() {
+		for k := range a.keys() {
 			v := a.panicGet(k)
-			if a.expired(v.expire) {
+			if !a.live(v) {
 				continue
 			}
 			if !yield(k, v.v) {
@@ -231,12 +761,84 @@ func (a *Cache[K, V]) Clear() {
 	a.policyMu.Lock()
 	defer a.policyMu.Unlock()
 
-	for k := range a.policy.Values() {
+	for k := range a.keys() {
 		v := a.panicDelete(k)
-		a.size.Add(-int64(v.size))
-		a.evict(k, v.v)
+		a.forget(v)
+		a.length.Add(-1)
+		a.size.Add(-v.loadMeta().size().int64())
+		a.removed(k, v.v, ReasonCleared)
 	}
 	a.policy.Clear()
+	clear(a.pinned)
+	a.pinnedSize.Store(0)
+	a.pinnedLen.Store(0)
+
+	if a.wheel != nil {
+		a.wheelMu.Lock()
+		a.wheel.clear()
+		a.wheelMu.Unlock()
+	}
+	if a.negative != nil {
+		a.negative.Clear()
+	}
+}
+
+// Like Clear without blocking, values are hidden immediately and evicted by a background
+// reclaim, returning a channel closed once done. Len and Size include values until evicted.
+func (a *Cache[K, V]) ClearFast() (reclaimed <-chan struct{}) {
+	a.gen.Add(1)
+	if a.negative != nil {
+		a.negative.ClearFast()
+	}
+
+	a.reclaimMu.Lock()
+	defer a.reclaimMu.Unlock()
+
+	if a.reclaimDone != nil {
+		a.reclaimAgain = true
+		return a.reclaimDone
+	}
+	done := make(chan struct{})
+	a.reclaimDone = done
+	go a.reclaim(done)
+	return done
+}
+
+func (a *Cache[K, V]) reclaim(done chan struct{}) {
+	for {
+		for a.reclaimSome() {
+		}
+
+		a.reclaimMu.Lock()
+		if a.reclaimAgain {
+			a.reclaimAgain = false
+			a.reclaimMu.Unlock()
+			continue
+		}
+		a.reclaimDone = nil
+		a.reclaimMu.Unlock()
+
+		close(done)
+		return
+	}
+}
+
+// Removes the cleared values of one scan, so each is found once rather than rescanning
+// live values. Removals don't hold the lock between values. Returns whether any found.
+func (a *Cache[K, V]) reclaimSome() bool {
+	var es []entry[K, V]
+	a.policyMu.RLock()
+	for k := range a.keys() {
+		if v := a.panicGet(k); a.cleared(v) {
+			es = append(es, entry[K, V]{k, v})
+		}
+	}
+	a.policyMu.RUnlock()
+
+	for _, e := range es {
+		a.remove(e.k, e.v, ReasonCleared)
+	}
+	return len(es) > 0
 }
 
 func (a *Cache[K, V]) Capacity() int64 {
@@ -257,6 +859,21 @@ func (a *Cache[K, V]) SwapCapacity(old, new int64) (swapped bool) {
 	return a.cap.CompareAndSwap(old, new)
 }
 
+// Unlimited when <= 0.
+func (a *Cache[K, V]) MaxEntries() int64 {
+	return a.maxEntries.Load()
+}
+
+// new <= 0 for unlimited.
+func (a *Cache[K, V]) SetMaxEntries(new int64) (old int64) {
+	return a.maxEntries.Swap(max(0, new))
+}
+
+// new <= 0 for unlimited.
+func (a *Cache[K, V]) SwapMaxEntries(old, new int64) (swapped bool) {
+	return a.maxEntries.CompareAndSwap(max(0, old), max(0, new))
+}
+
 // available (+/-) should not consider taken space in cache.
 func (a *Cache[K, V]) SetAvailableCapacity(available, max int64) {
 	new := a.size.Load() + available
@@ -268,12 +885,34 @@ func (a *Cache[K, V]) Stats() map[string]any {
 	a.policyMu.Lock()
 	defer a.policyMu.Unlock()
 
-	return map[string]any{"policy": a.policy.Stats()}
+	s := map[string]any{"policy": a.policy.Stats()}
+	if a.evictSkip != nil {
+		s["evictSkipGiveUps"] = a.evictSkipGiveUps.Load()
+	}
+	if a.wheel != nil {
+		a.wheelMu.Lock()
+		s["expirationIndexLen"] = a.wheel.len()
+		a.wheelMu.Unlock()
+	}
+	if a.negative != nil {
+		s["absentLen"] = a.negative.Len()
+	}
+	if l := a.tags.length.Load(); l > 0 {
+		s["taggedLen"] = l
+	}
+	if d := a.subs.drops.Load(); d > 0 {
+		s["eventDrops"] = d
+	}
+	if len(a.pinned) > 0 {
+		s["pinnedLen"] = len(a.pinned)
+		s["pinnedSize"] = a.pinnedSize.Load()
+	}
+	return s
 }
 
 func (a *Cache[K, V]) get(k K) (*CacheValue[V], bool) {
 	v, ok := a.items.Get(k)
-	if !ok || a.expired(v.expire) {
+	if !ok || !a.live(v) {
 		return nil, false
 	}
 	return v, true
@@ -303,36 +942,91 @@ func (a *Cache[K, V]) policyEvict() (_ K, ok bool) {
 		return a.policy.Evict()
 	}
 
+	var skips bool
 	evictSkip := func(k K) bool {
 		v := a.panicGet(k)
-		return a.evictSkip(k, v.v)
+		skip := a.evictSkip(k, v.v)
+		skips = skips || skip
+		return skip
+	}
+	k, ok := policy.EvictSkipLimit(a.policy, evictSkip, a.evictSkipLimit)
+	if !ok && skips {
+		a.evictSkipGiveUps.Add(1)
 	}
-	return a.policy.EvictSkip(evictSkip)
+	return k, ok
 }
 
-func (a *Cache[K, V]) panicPolicyAdd(k K) {
+// Pins v when o.pin, returning whether pinned.
+func (a *Cache[K, V]) panicPolicyAdd(k K, v *CacheValue[V], o setOptions) (pinned bool) {
 	a.policyMu.Lock()
 	defer a.policyMu.Unlock()
 
-	if !a.policy.Add(k) {
+	a.panicPolicyAddClass(k, o.priority)
+	return o.pin && a.pin(k, v)
+}
+
+// Under policyMu.
+func (a *Cache[K, V]) panicPolicyAddClass(k K, priority int) {
+	var ok bool
+	if a.classes != nil {
+		ok = a.classes.AddClass(k, priority)
+	} else {
+		ok = a.policy.Add(k)
+	}
+	if !ok {
 		panic(errutil.New(errutil.Tags{"alreadyInPolicy": k}))
 	}
 }
 
-func (a *Cache[K, V]) secsAfterExpireEpoch() uint32 {
-	return uint32(time.Since(a.expirationEpoch) / time.Second)
+// Estimated memory used per value by the cache itself, including the
+// CacheValue, its entry in the default map and the default policy's element.
+func EntryOverhead[K, V any]() int64 {
+	var k K
+	keySize := int64(unsafe.Sizeof(k))
+	const ptrSize = int64(unsafe.Sizeof(uintptr(0)))
+
+	value := int64(unsafe.Sizeof(CacheValue[V]{}))
+	mapEntry := keySize + 4*ptrSize        // key, value pointer plus sync map entry.
+	policyElement := 2*keySize + 4*ptrSize // list element plus key index entry.
+	return value + mapEntry + policyElement
 }
 
-func (a *Cache[K, V]) expire() uint32 {
+func (a *Cache[K, V]) msAfterExpireEpoch() int64 {
+	return int64(a.clock.Now().Sub(a.expirationEpoch) / time.Millisecond)
+}
+
+func (a *Cache[K, V]) expire() int64 {
 	if a.expiration <= 0 {
 		return 0
 	}
-	return a.secsAfterExpireEpoch() + a.expiration
+	expiration := a.expiration
+	if a.jitter > 0 {
+		j := int64(a.jitterSource() * float64(a.jitter+1)) // [0, jitter]
+		expiration -= min(max(j, 0), a.jitter)
+	}
+	return max(1, a.msAfterExpireEpoch()+expiration) // 0 is forever.
+}
+
+// Unexpired and not cleared.
+func (a *Cache[K, V]) live(v *CacheValue[V]) bool {
+	return !a.cleared(v) && !a.expired(v.loadMeta().expire())
+}
+
+func (a *Cache[K, V]) cleared(v *CacheValue[V]) bool {
+	return v.gen != a.gen.Load()
+}
+
+// Cleared values are evicted with ReasonCleared.
+func (a *Cache[K, V]) reason(v *CacheValue[V], r Reason) Reason {
+	if a.cleared(v) {
+		return ReasonCleared
+	}
+	return r
 }
 
-func (a *Cache[K, V]) expired(expire uint32) bool {
+func (a *Cache[K, V]) expired(expire int64) bool {
 	if expire == 0 {
 		return false
 	}
-	return a.secsAfterExpireEpoch() >= expire
+	return a.msAfterExpireEpoch() >= expire
 }
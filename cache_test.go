@@ -1,18 +1,27 @@
 package cache_test
 
 import (
+	"errors"
 	"fmt"
 	"maps"
+	"math"
 	"math/rand"
+	"runtime"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/graxinc/cache"
+	"github.com/graxinc/cache/clock"
+	"github.com/graxinc/cache/clock/clocktest"
 	cmaps "github.com/graxinc/cache/maps"
+	"github.com/graxinc/cache/policy"
+	"github.com/graxinc/cache/sizer"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -218,7 +227,7 @@ func TestCache_Sizer_race(t *testing.T) {
 	t.Parallel()
 
 	type cacheVal struct {
-		size uint32
+		size int64
 	}
 
 	o := cache.CacheOptions[int, cacheVal]{Capacity: 1000}
@@ -227,7 +236,7 @@ func TestCache_Sizer_race(t *testing.T) {
 	const goroutines = 10
 	goLoop := func(id int) {
 		for range 100 {
-			val := cacheVal{uint32(1 + id%goroutines)}
+			val := cacheVal{int64(1 + id%goroutines)}
 			a.SetS(1, val, val.size)
 		}
 	}
@@ -245,7 +254,7 @@ func TestCache_Sizer_race(t *testing.T) {
 
 		var size int64
 		for _, v := range a.All() {
-			size += int64(v.size)
+			size += v.size
 		}
 		if a.Size() != size {
 			t.Fatal("expected equal, walked size", size, "cache size", a.Size())
@@ -298,6 +307,102 @@ func TestCache_Clear_evicts(t *testing.T) {
 	a.Clear()
 
 	diffFatal(t, []int{1, 2}, evicts, sprintSorter[int]())
+	checkSize(t, a, 0, 0)
+}
+
+func TestCache_ClearFast(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var evicts []string
+	o := cache.CacheOptions[int, string]{
+		Capacity: 10,
+		EvictReason: func(k int, _ string, r cache.Reason) {
+			mu.Lock()
+			defer mu.Unlock()
+			evicts = append(evicts, fmt.Sprint(k, ":", r))
+		},
+	}
+	a := cache.NewCache(o)
+
+	a.Set(1, "a")
+	a.Set(2, "b")
+	a.SetST(3, "c", 1, "x")
+
+	done := a.ClearFast()
+
+	// hidden before reclaimed.
+	a.Set(2, "b2")
+	if _, ok := a.Get(1); ok {
+		t.Fatal("expected cleared")
+	}
+	if a.Delete(3) || a.InvalidateTag("x") != 0 {
+		t.Fatal("expected cleared")
+	}
+
+	<-done
+
+	checkKeys(t, a, 2)
+	checkSize(t, a, 1, 1)
+	mu.Lock()
+	diffFatal(t, []string{"1:cleared", "2:cleared", "3:cleared"}, evicts, sprintSorter[string]())
+	mu.Unlock()
+	diffFatal(t, nil, a.Stats()["taggedLen"])
+
+	<-a.ClearFast()
+	checkSize(t, a, 0, 0)
+}
+
+func TestCache_ClearFast_many(t *testing.T) {
+	t.Parallel()
+
+	o := cache.CacheOptions[int, int]{Capacity: 10_000}
+	a := cache.NewCache(o)
+	for k := range 5000 {
+		a.Set(k, k)
+	}
+
+	done := a.ClearFast()
+	for k := range 2000 { // live ahead of cleared.
+		a.Set(k+5000, k)
+	}
+	<-done
+
+	checkSize(t, a, 2000, 2000)
+	for k := range 2000 {
+		if _, ok := a.Peek(k + 5000); !ok {
+			t.Fatal(k)
+		}
+	}
+}
+
+func TestCache_ClearFast_random(t *testing.T) {
+	t.Parallel()
+
+	o := cache.CacheOptions[int, struct{}]{Capacity: 80}
+	a := cache.NewCache(o)
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rando := rand.New(rand.NewSource(int64(i))) //nolint:gosec
+			for j := range 1000 {
+				a.Set(rando.Intn(100), struct{}{})
+				if j%100 == 0 {
+					a.ClearFast()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	<-a.ClearFast()
+
+	checkSize(t, a, 0, 0)
+	checkAll(t, a, nil)
 }
 
 func TestCache_Clear_random(t *testing.T) {
@@ -336,6 +441,9 @@ func TestCache_Clear_random(t *testing.T) {
 	if a.Size() != 0 {
 		t.Fatal(a.Size())
 	}
+	if a.Len() != 0 {
+		t.Fatal(a.Len())
+	}
 
 	for i := range 100 {
 		if _, ok := a.Get(i); ok {
@@ -367,7 +475,7 @@ func TestCache_growPastCapacity(t *testing.T) {
 			a := cache.NewCache(cache.CacheOptions[int, struct{}]{Capacity: 4})
 
 			for i := range 2000 {
-				r := uint32(rando.Intn(3))
+				r := int64(rando.Intn(3))
 				a.SetAvailableCapacity(c.available, 50)
 				a.SetS(i, struct{}{}, r)
 			}
@@ -399,7 +507,7 @@ func TestCache_growPastCapacity(t *testing.T) {
 
 				var sizes []int64
 				for i := range c.iterations {
-					r := uint32(rando.Intn(3))
+					r := int64(rando.Intn(3))
 					avail := rando.Int63n(11) - 5 // [-5,5]
 					a.SetAvailableCapacity(avail, 50)
 					a.SetS(i, struct{}{}, r)
@@ -426,85 +534,936 @@ func TestCache_growPastCapacity(t *testing.T) {
 	})
 }
 
-func TestCache_SetCapacity_nonPositive(t *testing.T) {
+func TestCache_MaxEntries(t *testing.T) {
+	t.Parallel()
+
+	a := cache.NewCache(cache.CacheOptions[int, struct{}]{Capacity: 100, MaxEntries: 3})
+
+	for i := range 5 {
+		a.SetS(i, struct{}{}, 2)
+	}
+	checkKeys(t, a, 2, 3, 4)
+	checkSize(t, a, 3, 6)
+
+	old := a.SetMaxEntries(0)
+	diffFatal(t, int64(3), old)
+
+	for i := range 5 {
+		a.SetS(i+5, struct{}{}, 2)
+	}
+	checkSize(t, a, 8, 16)
+
+	if !a.SwapMaxEntries(0, 2) {
+		t.Fatal("expected swap")
+	}
+	diffFatal(t, int64(2), a.MaxEntries())
+
+	a.SetS(10, struct{}{}, 2)
+	checkKeys(t, a, 9, 10)
+	checkSize(t, a, 2, 4)
+
+	a.SetCapacity(3)
+	a.SetS(11, struct{}{}, 2)
+	checkKeys(t, a, 10, 11)
+}
+
+func TestCache_SetCapacity_nonPositive(t *testing.T) {
+	t.Parallel()
+
+	a := cache.NewCache(cache.CacheOptions[string, struct{}]{Capacity: 2})
+
+	if a.Capacity() != 2 { // precondition
+		t.Fatal(a.Capacity())
+	}
+
+	a.SetCapacity(0)
+
+	if a.Capacity() != 1 {
+		t.Fatal(a.Capacity())
+	}
+}
+
+func TestCache_SwapCapacity(t *testing.T) {
+	t.Parallel()
+
+	a := cache.NewCache(cache.CacheOptions[string, struct{}]{Capacity: 2})
+
+	if a.Capacity() != 2 { // precondition
+		t.Fatal(a.Capacity())
+	}
+
+	if a.SwapCapacity(1, 3) {
+		t.Fatal("expected false")
+	}
+	if a.Capacity() != 2 {
+		t.Fatal(a.Capacity())
+	}
+
+	if !a.SwapCapacity(2, 3) {
+		t.Fatal("expected true")
+	}
+	if a.Capacity() != 3 {
+		t.Fatal(a.Capacity())
+	}
+}
+
+func TestCache_Expiration(t *testing.T) {
+	t.Parallel()
+
+	do := func(t *testing.T, expiration time.Duration) {
+		t.Parallel()
+
+		clk := clocktest.NewFake(time.Unix(100, 0))
+		o := cache.CacheOptions[int, any]{Capacity: 10, Expiration: expiration, Clock: clk}
+		a := cache.NewCache(o)
+
+		setGet := func() {
+			t.Helper()
+
+			a.Set(5, nil)
+			checkKeys(t, a, 5)
+
+			if _, ok := a.Get(5); !ok {
+				t.Fatal("expected ok")
+			}
+		}
+
+		setGet()
+
+		clk.Advance(expiration - time.Millisecond)
+		if _, ok := a.Get(5); !ok {
+			t.Fatal("expected ok")
+		}
+
+		clk.Advance(time.Millisecond)
+		if _, ok := a.Get(5); ok {
+			t.Fatal("expected not ok")
+		}
+		checkKeys(t, a)
+
+		// refreshes the expiration
+		setGet()
+	}
+
+	for _, exp := range []time.Duration{time.Millisecond, 100 * time.Millisecond, time.Second, 2 * time.Second} {
+		t.Run(fmt.Sprint(exp), func(t *testing.T) { do(t, exp) })
+	}
+}
+
+func TestCache_Expiration_subMillisecond(t *testing.T) {
+	t.Parallel()
+
+	clk := clocktest.NewFake(time.Unix(100, 0))
+	o := cache.CacheOptions[int, any]{Capacity: 10, Expiration: time.Microsecond, Clock: clk}
+	a := cache.NewCache(o)
+
+	a.Set(5, nil)
+	if _, ok := a.Get(5); !ok {
+		t.Fatal("expected ok, rounded up to a millisecond")
+	}
+
+	clk.Advance(time.Millisecond)
+	if _, ok := a.Get(5); ok {
+		t.Fatal("expected not ok")
+	}
+}
+
+func TestCache_ExpireAfterAccess(t *testing.T) {
+	t.Parallel()
+
+	clk := clocktest.NewFake(time.Unix(100, 0))
+	o := cache.CacheOptions[int, any]{
+		Capacity:          10,
+		Expiration:        time.Second,
+		ExpireAfterAccess: true,
+		MaxLifetime:       3 * time.Second,
+		Clock:             clk,
+	}
+	a := cache.NewCache(o)
+
+	a.Set(5, nil)
+	a.Set(6, nil)
+
+	clk.Advance(900 * time.Millisecond)
+	if _, ok := a.Get(5); !ok {
+		t.Fatal("expected ok")
+	}
+	if !a.Touch(6) {
+		t.Fatal("expected touched")
+	}
+	if _, ok := a.Peek(6); !ok { // does not touch
+		t.Fatal("expected ok")
+	}
+
+	clk.Advance(900 * time.Millisecond)
+	checkKeys(t, a, 5, 6)
+	a.Touch(6)
+
+	clk.Advance(100 * time.Millisecond)
+	if _, ok := a.Peek(5); ok {
+		t.Fatal("expected not ok")
+	}
+	checkKeys(t, a, 6)
+
+	// capped by MaxLifetime
+	clk.Advance(800 * time.Millisecond)
+	a.Touch(6)
+	clk.Advance(300 * time.Millisecond)
+	if a.Touch(6) {
+		t.Fatal("expected expired at MaxLifetime")
+	}
+	if a.Touch(7) {
+		t.Fatal("expected missing")
+	}
+
+	// a replacing Set restarts the lifetime.
+	a.Set(6, nil)
+	clk.Advance(900 * time.Millisecond)
+	if !a.Touch(6) {
+		t.Fatal("expected touched")
+	}
+
+	// MaxLifetime to the millisecond.
+	o.MaxLifetime = 1500 * time.Millisecond
+	a = cache.NewCache(o)
+	a.Set(1, nil)
+	clk.Advance(900 * time.Millisecond)
+	a.Touch(1)
+	clk.Advance(599 * time.Millisecond)
+	if !a.Touch(1) {
+		t.Fatal("expected touched")
+	}
+	clk.Advance(time.Millisecond)
+	if a.Touch(1) {
+		t.Fatal("expected expired at MaxLifetime")
+	}
+}
+
+func TestCache_CacheValue_size(t *testing.T) {
+	t.Parallel()
+
+	// MaxLifetime is held apart, keeping values small.
+	diffFatal(t, uintptr(16), unsafe.Sizeof(cache.CacheValue[int32]{}))
+	diffFatal(t, uintptr(24), unsafe.Sizeof(cache.CacheValue[int]{}))
+}
+
+func TestCache_ExpirationJitter(t *testing.T) {
+	t.Parallel()
+
+	clk := clocktest.NewFake(time.Unix(100, 0))
+	jitters := []float64{0, 0.5, 0.999}
+	o := cache.CacheOptions[int, any]{
+		Capacity:                 10,
+		Expiration:               time.Second,
+		ExpirationJitter:         100 * time.Millisecond,
+		ExpirationJitterFraction: 0.2, // larger
+		JitterSource: func() float64 {
+			j := jitters[0]
+			jitters = jitters[1:]
+			return j
+		},
+		Clock: clk,
+	}
+	a := cache.NewCache(o)
+
+	a.Set(1, nil)
+	a.Set(2, nil)
+	a.Set(3, nil)
+
+	// deadlines within [800ms, 1s].
+	clk.Advance(799 * time.Millisecond)
+	checkKeys(t, a, 1, 2, 3)
+
+	clk.Advance(time.Millisecond)
+	checkKeys(t, a, 1, 2)
+
+	clk.Advance(99 * time.Millisecond)
+	checkKeys(t, a, 1, 2)
+
+	clk.Advance(time.Millisecond)
+	checkKeys(t, a, 1)
+
+	clk.Advance(99 * time.Millisecond)
+	checkKeys(t, a, 1)
+
+	clk.Advance(time.Millisecond)
+	checkKeys(t, a)
+}
+
+func TestCache_RemoveExpired(t *testing.T) {
+	t.Parallel()
+
+	do := func(t *testing.T, index bool) {
+		t.Parallel()
+
+		clk := clocktest.NewFake(time.Unix(100, 0))
+		var expired []int
+		o := cache.CacheOptions[int, any]{
+			Capacity:        10,
+			Expiration:      time.Second,
+			Clock:           clk,
+			ExpirationIndex: index,
+			EvictReason: func(k int, _ any, r cache.Reason) {
+				if r == cache.ReasonExpired {
+					expired = append(expired, k)
+				}
+			},
+		}
+		a := cache.NewCache(o)
+
+		a.Set(1, nil)
+		clk.Advance(500 * time.Millisecond)
+		a.Set(2, nil)
+		a.Set(3, nil)
+		a.Set(1, nil) // replaced, its first expiration is stale.
+
+		clk.Advance(500 * time.Millisecond)
+		diffFatal(t, 0, a.RemoveExpired())
+		checkSize(t, a, 3, 3)
+
+		clk.Advance(500 * time.Millisecond)
+		diffFatal(t, 3, a.RemoveExpired())
+		diffFatal(t, []int{2, 3, 1}, expired, sprintSorter[int]())
+		checkSize(t, a, 0, 0)
+
+		diffFatal(t, 0, a.RemoveExpired())
+		if index {
+			diffFatal(t, 0, a.Stats()["expirationIndexLen"])
+		}
+	}
+
+	t.Run("index", func(t *testing.T) { do(t, true) })
+	t.Run("scan", func(t *testing.T) { do(t, false) })
+}
+
+func TestCache_ExpirationIndex_churn(t *testing.T) {
+	t.Parallel()
+
+	o := cache.CacheOptions[int, int]{
+		Capacity:        10,
+		Expiration:      time.Hour,
+		ExpirationIndex: true,
+	}
+	a := cache.NewCache(o)
+
+	checkIndex := func() {
+		t.Helper()
+		diffFatal(t, a.Len(), a.Stats()["expirationIndexLen"])
+	}
+
+	for i := range 10_000 {
+		a.Set(i, i)
+		a.Set(i, i) // replaced
+		a.SetIfAbsent(i, i, 1)
+		if i%3 == 0 {
+			a.Delete(i)
+		}
+	}
+	checkIndex()
+	diffFatal(t, 0, a.RemoveExpired())
+	checkIndex()
+
+	<-a.ClearFast()
+	checkIndex()
+	diffFatal(t, 0, a.Len())
+
+	for i := range 100 {
+		a.Set(i, i)
+	}
+	a.Clear()
+	checkIndex()
+}
+
+func TestCache_RemoveExpired_random(t *testing.T) {
+	t.Parallel()
+
+	do := func(t *testing.T, expiration time.Duration) {
+		t.Parallel()
+
+		clk := clocktest.NewFake(time.Unix(100, 0))
+		o := cache.CacheOptions[int, any]{
+			Capacity:          1000,
+			Expiration:        expiration,
+			ExpireAfterAccess: true,
+			Clock:             clk,
+			ExpirationIndex:   true,
+		}
+		a := cache.NewCache(o)
+
+		rando := rand.New(rand.NewSource(5)) //nolint:gosec
+		for range 2000 {
+			k := rando.Intn(500)
+			switch rando.Intn(3) {
+			case 0:
+				a.Set(k, nil)
+			case 1:
+				a.Touch(k)
+			case 2:
+				clk.Advance(time.Duration(rando.Int63n(int64(expiration / 4))))
+				a.RemoveExpired()
+
+				// All skips expired, so all others were removed.
+				if l, all := a.Len(), len(maps.Collect(a.All())); l != all {
+					t.Fatal(l, all)
+				}
+				diffFatal(t, a.Len(), a.Stats()["expirationIndexLen"])
+			}
+		}
+	}
+
+	for _, exp := range []time.Duration{time.Second, time.Minute, 24 * time.Hour} {
+		t.Run(fmt.Sprint(exp), func(t *testing.T) { do(t, exp) })
+	}
+}
+
+func TestCache_Lookup(t *testing.T) {
+	t.Parallel()
+
+	clk := clocktest.NewFake(time.Unix(100, 0))
+	var evicts []cache.Reason
+	o := cache.CacheOptions[int, int]{
+		Capacity:           1,
+		Expiration:         time.Minute,
+		NegativeExpiration: time.Second,
+		Clock:              clk,
+		EvictReason:        func(_, _ int, r cache.Reason) { evicts = append(evicts, r) },
+	}
+	a := cache.NewCache(o)
+
+	lookup := func(k int, wantV int, wantState cache.State, wantErr error) {
+		t.Helper()
+		v, state, err := a.Lookup(k)
+		diffFatal(t, wantV, v)
+		diffFatal(t, wantState, state)
+		if err != wantErr { //nolint:errorlint
+			t.Fatal(err)
+		}
+	}
+
+	errGone := errors.New("gone")
+
+	a.Set(1, 10)
+	a.SetAbsent(2, nil)
+	a.SetAbsent(3, errGone)
+
+	lookup(1, 10, cache.StatePresent, nil)
+	lookup(2, 0, cache.StateAbsent, nil)
+	lookup(3, 0, cache.StateAbsent, errGone)
+	lookup(4, 0, cache.StateMissing, nil)
+	if _, ok := a.Get(2); ok {
+		t.Fatal("expected absent as a miss")
+	}
+	checkSize(t, a, 1, 1) // absent not counted.
+
+	a.SetAbsent(1, nil)
+	lookup(1, 0, cache.StateAbsent, nil)
+	diffFatal(t, []cache.Reason{cache.ReasonDeleted}, evicts)
+	checkSize(t, a, 0, 0)
+
+	a.Set(2, 20)
+	lookup(2, 20, cache.StatePresent, nil)
+
+	clk.Advance(time.Second)
+	lookup(1, 0, cache.StateMissing, nil)
+	lookup(3, 0, cache.StateMissing, nil)
+}
+
+func TestCache_Load(t *testing.T) {
+	t.Parallel()
+
+	const callers = 20
+
+	errOdd := errors.New("odd")
+	var loads, entered atomic.Int64
+	o := cache.CacheOptions[int, int]{
+		Capacity:           10,
+		NegativeExpiration: time.Minute,
+		Loader: func(k int) (int, error) {
+			loads.Add(1)
+			for entered.Load() < callers { // all callers are loading or waiting on this.
+				runtime.Gosched()
+			}
+			if k%2 == 1 {
+				return 0, errOdd
+			}
+			return k * 10, nil
+		},
+	}
+	a := cache.NewCache(o)
+
+	var wg sync.WaitGroup
+	for range callers / 2 {
+		for _, k := range []int{2, 3} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				entered.Add(1)
+				v, err := a.Load(k)
+				if k == 3 {
+					if !errors.Is(err, errOdd) || v != 0 {
+						t.Error(v, err)
+					}
+					return
+				}
+				if err != nil || v != 20 {
+					t.Error(v, err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	diffFatal(t, int64(2), loads.Load()) // once per key.
+
+	loads.Store(0)
+	if _, err := a.Load(3); !errors.Is(err, errOdd) {
+		t.Fatal(err)
+	}
+	if v, err := a.Load(2); err != nil || v != 20 {
+		t.Fatal(v, err)
+	}
+	if v, err := a.Load(6); err != nil || v != 60 {
+		t.Fatal(v, err)
+	}
+	diffFatal(t, int64(1), loads.Load())
+
+	a.SetAbsent(8, nil)
+	if _, err := a.Load(8); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatal(err)
+	}
+}
+
+func TestCache_InvalidateTag(t *testing.T) {
+	t.Parallel()
+
+	var evicts []string
+	o := cache.CacheOptions[string, int]{
+		Capacity: 4,
+		EvictReason: func(k string, _ int, r cache.Reason) {
+			evicts = append(evicts, k+":"+r.String())
+		},
+	}
+	a := cache.NewCache(o)
+
+	a.SetST("a", 1, 1, "x")
+	a.SetST("b", 1, 1, "x", "y")
+	a.SetST("c", 1, 1, "y")
+	a.SetS("d", 1, 1)
+
+	diffFatal(t, 2, a.InvalidateTag("x"))
+	checkKeys(t, a, "c", "d")
+	diffFatal(t, []string{"a:invalidated", "b:invalidated"}, evicts, sprintSorter[string]())
+	diffFatal(t, int64(1), a.Stats()["taggedLen"])
+
+	diffFatal(t, 0, a.InvalidateTag("x"))
+	diffFatal(t, 0, a.InvalidateTag("z"))
+
+	// replaced untagged.
+	evicts = nil
+	a.SetS("c", 2, 1)
+	diffFatal(t, 0, a.InvalidateTag("y"))
+	checkKeys(t, a, "c", "d")
+	diffFatal(t, []string{"c:replaced"}, evicts)
+
+	// evicted clean up.
+	for i := range 10 {
+		a.SetST(strconv.Itoa(i), i, 1, "n")
+	}
+	diffFatal(t, 4, a.InvalidateTag("n"))
+	diffFatal(t, nil, a.Stats()["taggedLen"])
+	checkSize(t, a, 0, 0)
+}
+
+func TestCache_Delete(t *testing.T) {
+	t.Parallel()
+
+	var evicts []cache.Reason
+	o := cache.CacheOptions[int, int]{
+		Capacity:    10,
+		EvictReason: func(_, _ int, r cache.Reason) { evicts = append(evicts, r) },
+	}
+	a := cache.NewCache(o)
+
+	a.Set(1, 1)
+	a.Set(2, 2)
+
+	diffFatal(t, true, a.Delete(1))
+	diffFatal(t, false, a.Delete(1))
+	diffFatal(t, false, a.Delete(3))
+
+	checkKeys(t, a, 2)
+	checkSize(t, a, 1, 1)
+	diffFatal(t, []cache.Reason{cache.ReasonDeleted}, evicts)
+
+	a.Set(1, 1) // re-added after removal from the policy.
+	checkKeys(t, a, 1, 2)
+}
+
+func TestCache_DeleteFunc(t *testing.T) {
+	t.Parallel()
+
+	var evicts []string
+	o := cache.CacheOptions[string, int]{
+		Capacity: 10,
+		EvictReason: func(k string, _ int, r cache.Reason) {
+			evicts = append(evicts, k+":"+r.String())
+		},
+	}
+	a := cache.NewCache(o)
+
+	a.Set("t1/a", 1)
+	a.Set("t1/b", 2)
+	a.Set("t2/a", 3)
+	a.SetST("t2/b", 4, 1, "x")
+
+	n := a.DeleteFunc(func(k string, v int) bool {
+		return strings.HasPrefix(k, "t1/") || v == 4
+	})
+	diffFatal(t, 3, n)
+	checkKeys(t, a, "t2/a")
+	checkSize(t, a, 1, 1)
+	diffFatal(t, []string{"t1/a:deleted", "t1/b:deleted", "t2/b:deleted"}, evicts, sprintSorter[string]())
+	diffFatal(t, nil, a.Stats()["taggedLen"])
+
+	diffFatal(t, 0, a.DeleteFunc(func(string, int) bool { return false }))
+}
+
+func TestCache_Pin(t *testing.T) {
+	t.Parallel()
+
+	var evicts []int
+	o := cache.CacheOptions[int, int]{
+		Capacity:          4,
+		MaxPinnedFraction: 0.75,
+		Evict:             func(k, _ int) { evicts = append(evicts, k) },
+	}
+	a := cache.NewCache(o)
+
+	if a.Pin(1) {
+		t.Fatal("expected missing")
+	}
+	a.Set(1, 1)
+	diffFatal(t, true, a.Pin(1))
+	diffFatal(t, true, a.Pin(1))
+	diffFatal(t, true, a.SetPinned(2, 2, 1))
+	diffFatal(t, false, a.SetPinned(3, 3, 2)) // over the fraction, set unpinned.
+	diffFatal(t, int64(2), a.PinnedSize())
+
+	// pinned are not evicted.
+	for i := 10; i < 20; i++ {
+		a.Set(i, i)
+	}
+	checkKeys(t, a, 1, 2, 18, 19)
+	checkSize(t, a, 4, 4)
+	diffFatal(t, []int{3, 10, 11, 12, 13, 14, 15, 16, 17}, evicts)
+
+	// replacing keeps pinned.
+	a.SetS(1, 10, 2)
+	diffFatal(t, int64(3), a.PinnedSize())
+	diffFatal(t, false, a.Pin(19))
+	diffFatal(t, map[string]any{"pinnedLen": 2, "pinnedSize": int64(3)}, a.Stats(), cmpopts.IgnoreMapEntries(func(k string, _ any) bool {
+		return k == "policy"
+	}))
+
+	diffFatal(t, true, a.Unpin(1))
+	diffFatal(t, false, a.Unpin(1))
+	diffFatal(t, int64(1), a.PinnedSize())
+	a.Set(20, 20)
+	a.Set(21, 21)
+	checkKeys(t, a, 2, 20, 21)
+
+	diffFatal(t, true, a.Delete(2))
+	diffFatal(t, int64(0), a.PinnedSize())
+	diffFatal(t, nil, a.Stats()["pinnedLen"])
+
+	// cleared values do not keep their key pinned.
+	a.SetPinned(3, 3, 1)
+	a.Clear()
+	checkSize(t, a, 0, 0)
+	diffFatal(t, int64(0), a.PinnedSize())
+
+	a.SetPinned(4, 4, 1)
+	<-a.ClearFast()
+	a.SetPinned(5, 5, 1)
+	done := a.ClearFast()
+	a.Set(5, 5)
+	<-done
+	checkKeys(t, a, 5)
+	diffFatal(t, int64(0), a.PinnedSize())
+}
+
+func TestCache_Pin_expired(t *testing.T) {
 	t.Parallel()
 
-	a := cache.NewCache(cache.CacheOptions[string, struct{}]{Capacity: 2})
-
-	if a.Capacity() != 2 { // precondition
-		t.Fatal(a.Capacity())
+	clk := clocktest.NewFake(time.Unix(100, 0))
+	var evicts []string
+	o := cache.CacheOptions[int, int]{
+		Capacity:          4,
+		Expiration:        time.Second,
+		Clock:             clk,
+		MaxPinnedFraction: 1,
+		EvictReason: func(k, _ int, r cache.Reason) {
+			evicts = append(evicts, strconv.Itoa(k)+"="+r.String())
+		},
 	}
+	a := cache.NewCache(o)
 
-	a.SetCapacity(0)
+	for k := range 4 {
+		diffFatal(t, true, a.SetPinned(k, k, 1))
+	}
+	clk.Advance(time.Second)
 
-	if a.Capacity() != 1 {
-		t.Fatal(a.Capacity())
+	// not removed by reads, keeping them lock-free.
+	if _, ok := a.Get(0); ok {
+		t.Fatal("expected expired")
 	}
+	checkSize(t, a, 4, 4)
+	diffFatal(t, int64(4), a.PinnedSize())
+
+	// removed when evictions find nothing else.
+	diffFatal(t, true, a.SetPinned(4, 4, 1))
+	a.Set(5, 5)
+	checkKeys(t, a, 4, 5)
+	diffFatal(t, int64(1), a.PinnedSize())
+	diffFatal(t, []string{"0=expired", "1=expired", "2=expired", "3=expired"}, evicts, sprintSorter[string]())
 }
 
-func TestCache_SwapCapacity(t *testing.T) {
+func TestCache_Pin_expired_getWithin(t *testing.T) {
 	t.Parallel()
 
-	a := cache.NewCache(cache.CacheOptions[string, struct{}]{Capacity: 2})
+	clk := clocktest.NewFake(time.Unix(100, 0))
+	var a *cache.Cache[int, int]
+	var gets []int
+	o := cache.CacheOptions[int, int]{
+		Capacity:          4,
+		Expiration:        time.Second,
+		Clock:             clk,
+		MaxPinnedFraction: 1,
+		Evict: func(k, _ int) {
+			if v, ok := a.Get(k); ok {
+				gets = append(gets, v)
+			}
+		},
+	}
+	a = cache.NewCache(o)
 
-	if a.Capacity() != 2 { // precondition
-		t.Fatal(a.Capacity())
+	diffFatal(t, true, a.SetPinned(0, 0, 1))
+	clk.Advance(time.Second)
+	a.Set(1, 1)
+
+	for k := range a.All() {
+		for i := range 2 {
+			if v, ok := a.Get(i); ok {
+				gets = append(gets, k*10+v)
+			}
+		}
 	}
+	diffFatal(t, []int{11}, gets)
 
-	if a.SwapCapacity(1, 3) {
-		t.Fatal("expected false")
+	a.Clear()
+	checkSize(t, a, 0, 0)
+}
+
+func TestCache_Pin_random(t *testing.T) {
+	t.Parallel()
+
+	o := cache.CacheOptions[int, struct{}]{Capacity: 80}
+	a := cache.NewCache(o)
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rando := rand.New(rand.NewSource(int64(i))) //nolint:gosec
+			for j := range 1000 {
+				k := rando.Intn(100)
+				switch rando.Intn(5) {
+				case 0:
+					a.SetPinned(k, struct{}{}, 1)
+				case 1:
+					a.Pin(k)
+				case 2:
+					a.Unpin(k)
+				case 3:
+					a.Delete(k)
+				default:
+					a.Set(k, struct{}{})
+				}
+				if j%200 == 0 {
+					a.ClearFast()
+				}
+			}
+		}()
 	}
-	if a.Capacity() != 2 {
-		t.Fatal(a.Capacity())
+	wg.Wait()
+
+	<-a.ClearFast()
+
+	checkSize(t, a, 0, 0)
+	diffFatal(t, int64(0), a.PinnedSize())
+}
+
+func TestCache_SetP(t *testing.T) {
+	t.Parallel()
+
+	var evicts []int
+	o := cache.CacheOptions[int, int]{
+		Capacity:   6,
+		Priorities: 3,
+		EvictReason: func(k, _ int, r cache.Reason) {
+			if r == cache.ReasonCapacity {
+				evicts = append(evicts, k)
+			}
+		},
 	}
+	a := cache.NewCache(o)
 
-	if !a.SwapCapacity(2, 3) {
-		t.Fatal("expected true")
+	a.SetP(20, 20, 1, 2)
+	a.SetP(21, 21, 1, 2)
+	a.SetP(10, 10, 1, 1)
+	a.SetP(11, 11, 1, 1)
+	a.Set(1, 1)
+	a.Set(2, 2)
+
+	// per weight: class 0 2/1, class 1 2/2, class 2 2/4.
+	for i := 3; i < 6; i++ {
+		a.Set(i, i)
 	}
-	if a.Capacity() != 3 {
-		t.Fatal(a.Capacity())
+	diffFatal(t, []int{1, 2, 3}, evicts)
+
+	// moves to a higher class.
+	a.SetP(4, 4, 1, 9)
+	a.Set(6, 6)
+	a.Set(7, 7)
+	diffFatal(t, []int{1, 2, 3, 5, 6}, evicts) // class 0 before class 1 on ties.
+	checkKeys(t, a, 4, 7, 10, 11, 20, 21)
+
+	// keeps its class through a pin.
+	diffFatal(t, true, a.Pin(11))
+	diffFatal(t, true, a.Unpin(11))
+
+	classes := a.Stats()["policy"].(map[string]any)["classes"].([]map[string]any)
+	var lens []int
+	for _, c := range classes {
+		lens = append(lens, c["len"].(int))
 	}
+	diffFatal(t, []int{1, 2, 3}, lens)
+	diffFatal(t, int64(5), classes[0]["evictions"])
 }
 
-func TestCache_Expiration(t *testing.T) {
+func TestCache_SetP_random(t *testing.T) {
 	t.Parallel()
 
-	do := func(t *testing.T, expiration time.Duration) {
-		t.Parallel()
+	o := cache.CacheOptions[int, struct{}]{Capacity: 80, Priorities: 3}
+	a := cache.NewCache(o)
 
-		a := cache.NewCache(cache.CacheOptions[int, any]{Capacity: 10, Expiration: expiration})
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		setGet := func() {
-			for timeout := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
-				if time.Now().After(timeout) {
-					t.Fatal("timeout")
+			rando := rand.New(rand.NewSource(int64(i))) //nolint:gosec
+			for range 1000 {
+				k := rando.Intn(100)
+				switch rando.Intn(4) {
+				case 0:
+					a.SetPinned(k, struct{}{}, 1)
+				case 1:
+					a.Unpin(k)
+				case 2:
+					a.Delete(k)
+				default:
+					a.SetP(k, struct{}{}, 1, rando.Intn(3))
 				}
+			}
+		}()
+	}
+	wg.Wait()
 
-				a.Set(5, nil)
-				checkKeys(t, a, 5)
+	var lens int
+	for _, c := range a.Stats()["policy"].(map[string]any)["classes"].([]map[string]any) {
+		lens += c["len"].(int)
+	}
+	diffFatal(t, a.Len(), lens+int(a.PinnedSize())) // sizes of 1.
+	checkSize(t, a, len(maps.Collect(a.All())), int64(a.Len()))
 
-				if _, ok := a.Get(5); ok { // precondition
-					break
-				}
-			}
-		}
+	a.Clear()
+	checkSize(t, a, 0, 0)
+}
 
-		setGet()
+func TestCache_Subscribe(t *testing.T) {
+	t.Parallel()
 
-		time.Sleep(max(time.Second, expiration))
+	a := cache.NewCache(cache.CacheOptions[int, int]{Capacity: 2})
 
-		if _, ok := a.Get(5); ok {
-			t.Fatal("expected not ok")
+	all, cancelAll := a.Subscribe(nil)
+	deletes, cancelDeletes := a.Subscribe(func(e cache.Event[int]) bool {
+		return e.Kind == cache.EventDelete
+	})
+	defer cancelDeletes()
+
+	a.Set(1, 1)
+	a.Set(1, 2)
+	a.Set(2, 2)
+	a.Set(3, 3)
+	a.Delete(3)
+
+	receive := func(ch <-chan cache.Event[int], n int) []cache.Event[int] {
+		t.Helper()
+		var es []cache.Event[int]
+		for range n {
+			es = append(es, <-ch)
 		}
-		checkKeys(t, a)
+		select {
+		case e := <-ch:
+			t.Fatal("unexpected", e)
+		default:
+		}
+		return es
+	}
 
-		// refreshes the expiration
-		setGet()
+	want := []cache.Event[int]{
+		{Kind: cache.EventSet, Key: 1},
+		{Kind: cache.EventReplace, Key: 1, Reason: cache.ReasonReplaced},
+		{Kind: cache.EventSet, Key: 2},
+		{Kind: cache.EventEvict, Key: 1, Reason: cache.ReasonCapacity},
+		{Kind: cache.EventSet, Key: 3},
+		{Kind: cache.EventDelete, Key: 3, Reason: cache.ReasonDeleted},
 	}
+	diffFatal(t, want, receive(all, len(want)))
+	diffFatal(t, want[5:], receive(deletes, 1))
 
-	for _, exp := range []time.Duration{100 * time.Millisecond, time.Second, 2 * time.Second} {
-		t.Run(fmt.Sprint(exp), func(t *testing.T) { do(t, exp) })
+	cancelAll()
+	if _, ok := <-all; ok {
+		t.Fatal("expected closed")
 	}
+	cancelAll() // idempotent
+
+	a.Set(4, 4)
+	diffFatal(t, nil, a.Stats()["eventDrops"])
+}
+
+func TestCache_Subscribe_drops(t *testing.T) {
+	t.Parallel()
+
+	a := cache.NewCache(cache.CacheOptions[int, int]{Capacity: 10, EventBuffer: 2})
+
+	events, cancel := a.Subscribe(nil)
+	defer cancel()
+
+	for i := range 5 {
+		a.Set(i, i) // does not block.
+	}
+
+	// drops newest.
+	diffFatal(t, 0, (<-events).Key)
+	diffFatal(t, 1, (<-events).Key)
+	diffFatal(t, int64(3), a.Stats()["eventDrops"])
+
+	a.Set(5, 5)
+	diffFatal(t, 5, (<-events).Key)
 }
 
 func TestCache_Sizer(t *testing.T) {
@@ -528,6 +1487,64 @@ func TestCache_Sizer(t *testing.T) {
 	checkSize(t, a, 2, 7)
 }
 
+func TestCache_SetS_large(t *testing.T) {
+	t.Parallel()
+
+	a := cache.NewCache(cache.CacheOptions[int, struct{}]{Capacity: math.MaxInt64})
+
+	const exact = 5 << 30
+	a.SetS(1, struct{}{}, exact)
+	checkSize(t, a, 1, exact)
+
+	const rounded = 5<<30 + 1
+	a.SetS(2, struct{}{}, rounded)
+	got := a.Size() - exact
+	if got < rounded || float64(got-rounded)/rounded > 1.0/(1<<18) {
+		t.Fatal(got)
+	}
+
+	a.Evict()
+	a.Evict()
+	checkSize(t, a, 0, 0)
+
+	a.SetS(3, struct{}{}, math.MaxInt64) // saturates
+	if a.Size() < 1<<54 {
+		t.Fatal(a.Size())
+	}
+
+	a.Evict()
+	checkSize(t, a, 0, 0)
+}
+
+func TestCache_SizerOption(t *testing.T) {
+	t.Parallel()
+
+	o := cache.CacheOptions[int, string]{Capacity: 10, Sizer: sizer.String[int]}
+	a := cache.NewCache(o)
+
+	a.Set(1, "abc")
+	a.Set(2, "")
+	checkSize(t, a, 2, 4)
+
+	a.SetS(3, "a", 5) // explicit size wins.
+	checkSize(t, a, 3, 9)
+}
+
+func TestCache_EntryOverhead(t *testing.T) {
+	t.Parallel()
+
+	overhead := cache.EntryOverhead[int, string]()
+	if overhead <= 0 {
+		t.Fatal(overhead)
+	}
+
+	o := cache.CacheOptions[int, string]{Capacity: 1000, EntryOverhead: true}
+	a := cache.NewCache(o)
+
+	a.SetS(1, "abc", 3)
+	checkSize(t, a, 1, 3+overhead)
+}
+
 func TestCache_Sizer_random(t *testing.T) {
 	t.Parallel()
 
@@ -543,7 +1560,7 @@ func TestCache_Sizer_random(t *testing.T) {
 	for range 10_000 {
 		n1 := rando.Intn(len(keys))
 		n2 := rando.Intn(len(keys))
-		n3 := uint32(rando.Intn(11))
+		n3 := int64(rando.Intn(11))
 		a.Get(keys[n1]) // to jiggle order.
 		a.SetS(keys[n2], struct{}{}, n3)
 	}
@@ -553,6 +1570,272 @@ func TestCache_Sizer_random(t *testing.T) {
 	}
 }
 
+func TestCache_SetIfAbsent(t *testing.T) {
+	t.Parallel()
+
+	var evicts []string
+	evict := func(k int, v string) {
+		evicts = append(evicts, v)
+	}
+	a := cache.NewCache(cache.CacheOptions[int, string]{Capacity: 10, Evict: evict})
+
+	v, inserted, ok := a.SetIfAbsent(1, "a", 2)
+	diffFatal(t, "a", v)
+	diffFatal(t, true, inserted)
+	diffFatal(t, true, ok)
+
+	v, inserted, ok = a.SetIfAbsent(1, "b", 3)
+	diffFatal(t, "a", v)
+	diffFatal(t, false, inserted)
+	diffFatal(t, true, ok)
+
+	checkAll(t, a, map[int]string{1: "a"})
+	checkSize(t, a, 1, 2)
+	diffFatal(t, []string(nil), evicts)
+}
+
+func TestCache_SetIfAbsent_concurrent(t *testing.T) {
+	t.Parallel()
+
+	a := cache.NewCache(cache.CacheOptions[int, int]{Capacity: 1000})
+
+	for k := range 100 {
+		var inserts atomic.Int64
+		var wg sync.WaitGroup
+		winners := make([]int, 10)
+		for i := range winners {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, inserted, _ := a.SetIfAbsent(k, i, 1)
+				if inserted {
+					inserts.Add(1)
+				}
+				winners[i] = v
+			}()
+		}
+		wg.Wait()
+
+		if v := inserts.Load(); v != 1 {
+			t.Fatal(v)
+		}
+		got, _ := a.Peek(k)
+		for _, w := range winners {
+			if w != got {
+				t.Fatal(winners, got)
+			}
+		}
+	}
+	checkSize(t, a, 100, 100)
+}
+
+func TestCache_SetIfAbsent_rejected(t *testing.T) {
+	t.Parallel()
+
+	var evicts []string
+	evict := func(k int, v string, r cache.Reason) {
+		evicts = append(evicts, v+"="+r.String())
+	}
+
+	// full and all skipped.
+	o := cache.CacheOptions[int, string]{
+		Capacity:    2,
+		EvictReason: evict,
+		EvictSkip:   func(int, string) bool { return true },
+	}
+	a := cache.NewCache(o)
+	a.Set(1, "a")
+	a.Set(2, "b")
+
+	for _, v := range []string{"c", "d"} {
+		got, inserted, ok := a.SetIfAbsent(3, v, 1)
+		diffFatal(t, "", got)
+		diffFatal(t, false, inserted)
+		diffFatal(t, false, ok)
+	}
+	if _, ok := a.Peek(3); ok {
+		t.Fatal("expected missing")
+	}
+	diffFatal(t, []string{"c=rejected", "d=rejected"}, evicts)
+
+	// existing are still returned.
+	got, inserted, ok := a.SetIfAbsent(1, "e", 1)
+	diffFatal(t, "a", got)
+	diffFatal(t, false, inserted)
+	diffFatal(t, true, ok)
+
+	// not admitted.
+	evicts = nil
+	o = cache.CacheOptions[int, string]{
+		Capacity:     10,
+		EvictReason:  evict,
+		Admit:        func(k int, _ string, _ int64) bool { return k != 3 },
+		AdmitMaxSize: 5,
+	}
+	a = cache.NewCache(o)
+
+	got, inserted, ok = a.SetIfAbsent(3, "a", 1)
+	diffFatal(t, "", got)
+	diffFatal(t, false, inserted)
+	diffFatal(t, false, ok)
+
+	_, inserted, ok = a.SetIfAbsent(4, "b", 6)
+	diffFatal(t, false, inserted)
+	diffFatal(t, false, ok)
+
+	checkSize(t, a, 0, 0)
+	diffFatal(t, []string{"a=rejected", "b=rejected"}, evicts)
+
+	// set while rejecting.
+	evicts = nil
+	o.Admit = func(k int, v string, _ int64) bool {
+		if v == "a" {
+			a.Set(k, "b")
+		}
+		return v != "a"
+	}
+	a = cache.NewCache(o)
+
+	got, inserted, ok = a.SetIfAbsent(5, "a", 1)
+	diffFatal(t, "", got)
+	diffFatal(t, false, inserted)
+	diffFatal(t, false, ok)
+	checkKeys(t, a, 5)
+	diffFatal(t, []string{"a=rejected"}, evicts)
+}
+
+// Without the optional interfaces, see policy.Remover and maps.AbsentAdder.
+func TestCache_basicMapAndPolicy(t *testing.T) {
+	t.Parallel()
+
+	type basicMap struct {
+		cmaps.Map[int, *cache.CacheValue[int]]
+	}
+	type basicPolicy struct{ policy.Policy[int] }
+
+	var evicts []int
+	o := cache.CacheOptions[int, int]{
+		Capacity: 4,
+		Evict:    func(k, _ int) { evicts = append(evicts, k) },
+		EvictSkip: func(k, _ int) bool {
+			return k == 0
+		},
+		EvictSkipLimit: 1,
+		MapCreator: func() cmaps.Map[int, *cache.CacheValue[int]] {
+			return basicMap{cmaps.NewBuiltin[int, *cache.CacheValue[int]]()}
+		},
+		PolicyCreator: func() policy.Policy[int] {
+			return basicPolicy{policy.NewARC[int]()}
+		},
+	}
+	a := cache.NewCache(o)
+
+	for k := range 4 {
+		_, inserted, ok := a.SetIfAbsent(k, k, 1)
+		diffFatal(t, true, inserted)
+		diffFatal(t, true, ok)
+	}
+	v, inserted, ok := a.SetIfAbsent(2, 5, 1)
+	diffFatal(t, 2, v)
+	diffFatal(t, false, inserted)
+	diffFatal(t, true, ok)
+
+	diffFatal(t, true, a.Delete(3))
+	diffFatal(t, true, a.Pin(2))
+	diffFatal(t, true, a.Unpin(2))
+	checkKeys(t, a, 0, 1, 2)
+
+	// full, rejecting after skipping the coldest.
+	a.Set(4, 4)
+	a.Set(5, 5)
+	diffFatal(t, []int{3, 5}, evicts)
+	diffFatal(t, int64(1), a.Stats()["evictSkipGiveUps"])
+	checkKeys(t, a, 0, 1, 2, 4)
+}
+
+func TestCache_Admit(t *testing.T) {
+	t.Parallel()
+
+	var evicts []string
+	evict := func(k string, _ struct{}, r cache.Reason) {
+		evicts = append(evicts, k+"="+r.String())
+	}
+	admit := func(k string, _ struct{}, _ int64) bool {
+		return k != "denied"
+	}
+	o := cache.CacheOptions[string, struct{}]{
+		Capacity:         10,
+		EvictReason:      evict,
+		Admit:            admit,
+		AdmitMaxSize:     6,
+		AdmitMaxFraction: 0.5,
+	}
+	a := cache.NewCache(o)
+
+	a.SetS("a", struct{}{}, 5)
+	a.SetS("b", struct{}{}, 6) // over fraction
+	a.SetS("c", struct{}{}, 7) // over size
+	a.Set("denied", struct{}{})
+	a.SetIfAbsent("d", struct{}{}, 11)
+
+	checkKeys(t, a, "a")
+	checkSize(t, a, 1, 5)
+	diffFatal(t, []string{"b=rejected", "c=rejected", "denied=rejected", "d=rejected"}, evicts)
+
+	evicts = nil
+	a.SetS("a", struct{}{}, 1)
+	a.Evict()
+	a.SetS("e", struct{}{}, 5)
+	a.Clear()
+	diffFatal(t, []string{"a=replaced", "a=capacity", "e=cleared"}, evicts)
+}
+
+func TestCache_ExternalSize(t *testing.T) {
+	t.Parallel()
+
+	var external atomic.Int64
+	o := cache.CacheOptions[int, struct{}]{Capacity: 5, ExternalSize: external.Load}
+	a := cache.NewCache(o)
+
+	for i := range 5 {
+		a.Set(i, struct{}{})
+	}
+	checkSize(t, a, 5, 5)
+
+	external.Store(2)
+	a.Set(5, struct{}{})
+
+	checkKeys(t, a, 3, 4, 5)
+	checkSize(t, a, 3, 3)
+}
+
+func TestCache_EvictSkipLimit(t *testing.T) {
+	t.Parallel()
+
+	var skips int
+	evictSkip := func(k int, _ struct{}) bool {
+		skips++
+		return k < 5
+	}
+	o := cache.CacheOptions[int, struct{}]{Capacity: 10, EvictSkip: evictSkip, EvictSkipLimit: 3}
+	a := cache.NewCache(o)
+
+	for i := range 10 {
+		a.Set(i, struct{}{})
+	}
+
+	noSpace := a.Evict()
+	diffFatal(t, true, noSpace)
+	diffFatal(t, 3, skips)
+
+	noSpace = a.Evict()
+	diffFatal(t, false, noSpace)
+	diffFatal(t, 6, skips) // 3,4 skipped then 5 evicted.
+
+	got := a.Stats()["evictSkipGiveUps"]
+	diffFatal(t, int64(1), got)
+}
+
 func TestCache_Stats(t *testing.T) {
 	t.Parallel()
 
@@ -693,6 +1976,22 @@ func BenchmarkCache_getSet(b *testing.B) {
 	b.Log("hit/miss/ratio", h, m, float64(h)/float64(m))
 }
 
+func BenchmarkCache_replace(b *testing.B) {
+	o := cache.CacheOptions[int, int]{Capacity: 1000}
+	a := cache.NewCache(o)
+	for k := range 1000 {
+		a.Set(k, k)
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		var k int
+		for pb.Next() {
+			a.Set(k%1000, k)
+			k++
+		}
+	})
+}
+
 func BenchmarkCache_getSet_bucketed(b *testing.B) {
 	rando := rand.New(rand.NewSource(5)) //nolint:gosec
 
@@ -766,6 +2065,33 @@ func BenchmarkCache_getSet_bucketed(b *testing.B) {
 	b.Log("hit/miss/ratio", h, m, float64(h)/float64(m))
 }
 
+func BenchmarkCache_get_expiration(b *testing.B) {
+	clocks := map[string]clock.Clock{
+		"system": clock.System{},
+		"coarse": clock.Shared(),
+	}
+	for name, clk := range clocks {
+		b.Run(name, func(b *testing.B) {
+			o := cache.CacheOptions[int, int]{Capacity: 1000, Expiration: time.Hour, Clock: clk}
+			a := cache.NewCache(o)
+			for i := range 1000 {
+				a.Set(i, i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				var i int
+				for pb.Next() {
+					a.Peek(i % 1000)
+					i++
+				}
+			})
+		})
+	}
+}
+
 func checkAll[K comparable, V any](t testing.TB, c *cache.Cache[K, V], want map[K]V) {
 	t.Helper()
 
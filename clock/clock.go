@@ -0,0 +1,69 @@
+// Time sources for cache expiration.
+package clock
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Concurrent safe.
+type Clock interface {
+	Now() time.Time
+}
+
+// Uses time.Now.
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// A Clock updated by a ticker, so Now is an atomic load rather than a clock read.
+// Now lags real time by up to the resolution. Share between caches, see Shared.
+// Concurrent safe.
+type Coarse struct {
+	start   time.Time // with a monotonic reading, kept by Now.
+	elapsed atomic.Int64
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Must Stop.
+func NewCoarse(resolution time.Duration) *Coarse {
+	c := &Coarse{start: time.Now(), stop: make(chan struct{}), done: make(chan struct{})}
+	t := time.NewTicker(resolution)
+	go func() {
+		defer close(c.done)
+		defer t.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-t.C:
+				c.elapsed.Store(int64(time.Since(c.start)))
+			}
+		}
+	}()
+	return c
+}
+
+var shared = sync.OnceValue(func() *Coarse {
+	return NewCoarse(time.Millisecond)
+})
+
+// A millisecond Coarse shared by all callers, never stopped.
+func Shared() *Coarse {
+	return shared()
+}
+
+func (c *Coarse) Now() time.Time {
+	return c.start.Add(time.Duration(c.elapsed.Load()))
+}
+
+// Idempotent. Now no longer advances.
+func (c *Coarse) Stop() {
+	c.once.Do(func() { close(c.stop) })
+	<-c.done
+}
@@ -0,0 +1,42 @@
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/graxinc/cache/clock"
+)
+
+func TestCoarse(t *testing.T) {
+	t.Parallel()
+
+	c := clock.NewCoarse(time.Millisecond)
+	defer c.Stop()
+
+	start := c.Now()
+	for timeout := time.Now().Add(5 * time.Second); !c.Now().After(start); time.Sleep(time.Millisecond) {
+		if time.Now().After(timeout) {
+			t.Fatal("timeout")
+		}
+	}
+
+	if d := time.Since(c.Now()); d < 0 || d > time.Second {
+		t.Fatal(d)
+	}
+
+	c.Stop()
+	c.Stop() // idempotent
+	stopped := c.Now()
+	time.Sleep(5 * time.Millisecond)
+	if got := c.Now(); !got.Equal(stopped) {
+		t.Fatal(got, stopped)
+	}
+}
+
+func TestShared(t *testing.T) {
+	t.Parallel()
+
+	if clock.Shared() != clock.Shared() {
+		t.Fatal("expected same")
+	}
+}
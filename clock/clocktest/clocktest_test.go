@@ -0,0 +1,31 @@
+package clocktest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/graxinc/cache/clock"
+	"github.com/graxinc/cache/clock/clocktest"
+)
+
+func TestFake(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(100, 0)
+	var c clock.Clock = clocktest.NewFake(start)
+	f := c.(*clocktest.Fake)
+
+	if got := c.Now(); !got.Equal(start) {
+		t.Fatal(got)
+	}
+
+	f.Advance(time.Second)
+	if got := c.Now(); !got.Equal(start.Add(time.Second)) {
+		t.Fatal(got)
+	}
+
+	f.Set(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Fatal(got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/graxinc/cache"
+	"github.com/graxinc/cache/clock"
 	"github.com/graxinc/cache/maps"
 	"github.com/graxinc/cache/policy"
 )
@@ -30,6 +31,20 @@ type Node[T Releaser] struct {
 	handles atomic.Int64
 
 	released atomic.Bool // for the node release
+
+	// only for nodes within a Cache.
+	shared *shared // might be nil
+	size   int64
+	zombie atomic.Bool // evicted while possibly still having Handles.
+}
+
+// State shared by a Cache and its Nodes.
+type shared struct {
+	leaks   *leakTracker // might be nil
+	release *releasePool // might be nil
+
+	zombieLen  atomic.Int64
+	zombieSize atomic.Int64
 }
 
 // v is Released after all Handles have been Released plus the node Release.
@@ -49,7 +64,11 @@ func (n *Node[T]) Handle() (_ Handle[T], ok bool) {
 	if !n.inc() {
 		return nil, false
 	}
-	return &handle[T]{n: n}, true
+	h := &handle[T]{n: n}
+	if n.shared != nil && n.shared.leaks != nil {
+		watchHandle(n.shared.leaks, h)
+	}
+	return h, true
 }
 
 // Intended for metrics.
@@ -77,13 +96,36 @@ func (n *Node[T]) inc() (ok bool) {
 func (n *Node[T]) dec() {
 	// going past -1 protected via bool swaps
 	if v := n.handles.Add(-1); v < 0 {
-		n.value.Release()
+		if n.shared != nil && n.shared.release != nil {
+			n.shared.release.submit(n)
+			return
+		}
+		n.finish()
+	}
+}
+
+func (n *Node[T]) finish() {
+	n.value.Release()
+	if n.zombie.Load() {
+		n.shared.zombieLen.Add(-1)
+		n.shared.zombieSize.Add(-n.size)
+	}
+}
+
+// Must be called before the node Release, once.
+func (n *Node[T]) evicted() {
+	if n.shared == nil {
+		return
 	}
+	n.shared.zombieLen.Add(1)
+	n.shared.zombieSize.Add(n.size)
+	n.zombie.Store(true)
 }
 
 type handle[T Releaser] struct {
 	n        *Node[T]
 	released atomic.Bool
+	leak     *leakRecord // might be nil
 }
 
 func (h *handle[T]) Value() T {
@@ -92,6 +134,9 @@ func (h *handle[T]) Value() T {
 
 func (h *handle[T]) Release() {
 	if !h.released.Swap(true) {
+		if h.leak != nil {
+			unwatchHandle(h.n.shared.leaks, h)
+		}
 		h.n.dec()
 	}
 }
@@ -101,28 +146,97 @@ func (h *handle[T]) Release() {
 // to know all callers are done with the value.
 // Concurrent safe.
 type Cache[K comparable, V Releaser] struct {
-	cache *cache.Cache[K, *Node[V]]
+	cache  *cache.Cache[K, *Node[V]]
+	shared *shared
+	sizer  func(K, V) int64 // might be nil
+	touch  bool             // whether Get touches.
 }
 
 type CacheOptions[K any, V Releaser] struct {
-	Expiration    time.Duration                                   // Defaults to forever.
-	Capacity      int64                                           // Defaults to 100.
-	MapCreator    func() maps.Map[K, *cache.CacheValue[*Node[V]]] // defaults to maps.Sync
-	PolicyCreator func() policy.Policy[K]                         // defaults to policy.NewARC
-	Evict         func(_ K, _ V, Release func())                  // Caller must Release, not V.Release.
-	EvictSkip     bool
+	Expiration     time.Duration                                   // Millisecond resolution. Defaults to forever.
+	Clock          clock.Clock                                     // For expiration. Defaults to clock.System, clock.Shared avoids reading the time per Get.
+	Capacity       int64                                           // Max total size of values. Defaults to 100.
+	MaxEntries     int64                                           // Max count of values, in addition to Capacity. Defaults to unlimited.
+	MapCreator     func() maps.Map[K, *cache.CacheValue[*Node[V]]] // defaults to maps.Sync
+	PolicyCreator  func() policy.Policy[K]                         // defaults to policy.NewARC
+	Evict          func(_ K, _ V, Release func())                  // Caller must Release, not V.Release.
+	EvictSkip      bool
+	EvictSkipLimit int              // Max values checked with EvictSkip per eviction. Defaults to unlimited.
+	Sizer          func(K, V) int64 // Size used by Set, see the sizer package. Defaults to 1. Might be called concurrently.
+	EntryOverhead  bool             // Adds cache.EntryOverhead to each value size.
+
+	// Values not admitted by a Set are evicted, see cache.CacheOptions.Admit.
+	Admit            func(K, V, int64) bool // Might be called concurrently.
+	AdmitMaxSize     int64                  // Rejects larger values. Defaults to unlimited.
+	AdmitMaxFraction float64                // Rejects values larger than this fraction of Capacity. Defaults to unlimited.
+
+	LeakDetection bool       // Records the acquiring stack of live Handles, see Cache.Leaks. Costly, intended for debugging.
+	LeakReport    func(Leak) // Called for Handles garbage collected while unreleased. Requires LeakDetection. Might be called concurrently.
+	LeakRelease   bool       // Releases Handles garbage collected while unreleased. Requires LeakDetection.
+
+	// Whether zombies (evicted values still held by Handles) count against Capacity,
+	// evicting more while they are outstanding. Zombies beyond Capacity will evict all.
+	ZombieCapacity bool
+
+	// Goroutines running final value Releases, instead of whichever caller released last. See Cache.Drain.
+	// Defaults to 0, the caller.
+	ReleaseWorkers int
+	ReleaseQueue   int // Pending Releases before callers block. Requires ReleaseWorkers. Defaults to ReleaseWorkers.
+
+	// See cache.CacheOptions.ExpireAfterAccess.
+	ExpireAfterAccess bool
+	MaxLifetime       time.Duration // Caps ExpireAfterAccess from the Set. Millisecond resolution. Defaults to unlimited.
+	ExpirationIndex   bool          // See cache.CacheOptions.ExpirationIndex.
+
+	// See cache.CacheOptions.ExpirationJitter.
+	ExpirationJitter         time.Duration
+	ExpirationJitterFraction float64
+	JitterSource             func() float64
+
+	EventBuffer int // See cache.CacheOptions.EventBuffer.
+
+	MaxPinnedFraction float64 // See cache.CacheOptions.MaxPinnedFraction.
+
+	// See cache.CacheOptions.Priorities.
+	Priorities      int
+	PriorityWeights []float64
 }
 
 func NewCache[K comparable, V Releaser](o CacheOptions[K, V]) Cache[K, V] {
+	s := &shared{}
+	if o.LeakDetection {
+		s.leaks = newLeakTracker(o.LeakReport, o.LeakRelease)
+	}
+	if o.ReleaseWorkers > 0 {
+		if o.ReleaseQueue <= 0 {
+			o.ReleaseQueue = o.ReleaseWorkers
+		}
+		s.release = newReleasePool(o.ReleaseWorkers, o.ReleaseQueue)
+	}
+
 	evict := func(k K, v *Node[V]) {
+		v.evicted()
 		v.Release()
 	}
 	if o.Evict != nil {
 		evict = func(k K, v *Node[V]) {
+			v.evicted()
 			o.Evict(k, v.Value(), v.Release)
 		}
 	}
 
+	var externalSize func() int64
+	if o.ZombieCapacity {
+		externalSize = s.zombieSize.Load
+	}
+
+	var admit func(K, *Node[V], int64) bool
+	if o.Admit != nil {
+		admit = func(k K, n *Node[V], size int64) bool {
+			return o.Admit(k, n.Value(), size)
+		}
+	}
+
 	var evictSkip func(K, *Node[V]) bool
 	if o.EvictSkip {
 		evictSkip = func(k K, n *Node[V]) bool {
@@ -131,14 +245,32 @@ func NewCache[K comparable, V Releaser](o CacheOptions[K, V]) Cache[K, V] {
 	}
 
 	c := cache.NewCache(cache.CacheOptions[K, *Node[V]]{
-		Expiration:    o.Expiration,
-		Evict:         evict,
-		Capacity:      o.Capacity,
-		MapCreator:    o.MapCreator,
-		PolicyCreator: o.PolicyCreator,
-		EvictSkip:     evictSkip,
+		Expiration:               o.Expiration,
+		Clock:                    o.Clock,
+		ExpireAfterAccess:        o.ExpireAfterAccess,
+		MaxLifetime:              o.MaxLifetime,
+		ExpirationIndex:          o.ExpirationIndex,
+		ExpirationJitter:         o.ExpirationJitter,
+		ExpirationJitterFraction: o.ExpirationJitterFraction,
+		JitterSource:             o.JitterSource,
+		EventBuffer:              o.EventBuffer,
+		MaxPinnedFraction:        o.MaxPinnedFraction,
+		Priorities:               o.Priorities,
+		PriorityWeights:          o.PriorityWeights,
+		Evict:                    evict,
+		Capacity:                 o.Capacity,
+		MaxEntries:               o.MaxEntries,
+		MapCreator:               o.MapCreator,
+		PolicyCreator:            o.PolicyCreator,
+		EvictSkip:                evictSkip,
+		EvictSkipLimit:           o.EvictSkipLimit,
+		ExternalSize:             externalSize,
+		EntryOverhead:            o.EntryOverhead,
+		Admit:                    admit,
+		AdmitMaxSize:             o.AdmitMaxSize,
+		AdmitMaxFraction:         o.AdmitMaxFraction,
 	})
-	return Cache[K, V]{c}
+	return Cache[K, V]{c, s, o.Sizer, o.ExpireAfterAccess}
 }
 
 // Results ordered by most->least. Will block.
@@ -180,25 +312,118 @@ func (a Cache[K, V]) Get(k K) (Handle[V], bool) {
 	if !ok {
 		return nil, false
 	}
+	if a.touch {
+		a.cache.Touch(k)
+	}
 	a.cache.Promote(k)
 	return h, true
 }
 
-// Alias for SetS(k,v,1).
+// See cache.Cache.Touch. Does not Promote.
+func (a Cache[K, V]) Touch(k K) bool {
+	return a.cache.Touch(k)
+}
+
+// SetS using CacheOptions.Sizer.
 func (a Cache[K, V]) Set(k K, v V) Handle[V] {
-	return a.SetS(k, v, 1)
+	size := int64(1)
+	if a.sizer != nil {
+		size = a.sizer(k, v)
+	}
+	return a.SetS(k, v, size)
 }
 
 // Replaces existing values, which are evicted.
 // A min size of 1 will be used.
 // Caller must release Handle.
-func (a Cache[K, V]) SetS(k K, v V, size uint32) Handle[V] {
-	n := NewNode(v)
-	h, _ := n.Handle()
-	a.cache.SetS(k, n, size)
+func (a Cache[K, V]) SetS(k K, v V, size int64) Handle[V] {
+	return a.SetST(k, v, size)
+}
+
+// Like SetS, tagging the value for InvalidateTag.
+// Caller must release Handle.
+func (a Cache[K, V]) SetST(k K, v V, size int64, tags ...string) Handle[V] {
+	n, h := a.newNode(v, size)
+	a.cache.SetST(k, n, size, tags...)
+	return h
+}
+
+// Like SetS, in a priority class, see cache.Cache.SetP.
+// Caller must release Handle.
+func (a Cache[K, V]) SetP(k K, v V, size int64, priority int) Handle[V] {
+	n, h := a.newNode(v, size)
+	a.cache.SetP(k, n, size, priority)
 	return h
 }
 
+// Like SetS, also pinning the value, see cache.Cache.Pin.
+// Caller must release Handle.
+func (a Cache[K, V]) SetPinned(k K, v V, size int64) (_ Handle[V], pinned bool) {
+	n, h := a.newNode(v, size)
+	pinned = a.cache.SetPinned(k, n, size)
+	return h, pinned
+}
+
+// A Node for the cache, sized as the cache records it, with a Handle for the caller.
+func (a Cache[K, V]) newNode(v V, size int64) (*Node[V], Handle[V]) {
+	n := &Node[V]{value: v, shared: a.shared, size: a.cache.EntrySize(size)}
+	h, _ := n.Handle()
+	return n, h
+}
+
+// See cache.Cache.Pin.
+func (a Cache[K, V]) Pin(k K) bool {
+	return a.cache.Pin(k)
+}
+
+// See cache.Cache.Unpin.
+func (a Cache[K, V]) Unpin(k K) bool {
+	return a.cache.Unpin(k)
+}
+
+// Removes values tagged by SetST, releasing once unheld. Returns the count removed.
+func (a Cache[K, V]) InvalidateTag(tag string) (removed int) {
+	return a.cache.InvalidateTag(tag)
+}
+
+// Releases once unheld, returning whether removed.
+func (a Cache[K, V]) Delete(k K) bool {
+	return a.cache.Delete(k)
+}
+
+// Deletes values matching f, releasing once unheld. Returns the count removed.
+// See cache.Cache.DeleteFunc.
+func (a Cache[K, V]) DeleteFunc(f func(K, V) bool) (removed int) {
+	return a.cache.DeleteFunc(func(k K, n *Node[V]) bool {
+		return f(k, n.Value())
+	})
+}
+
+// Like SetS, except a Handle to an existing value is returned with !inserted, in which case
+// v is not used and remains the caller's to Release. !ok when v was rejected, see
+// cache.Cache.SetIfAbsent, the Handle then holding v as with SetS.
+// Caller must release Handle.
+func (a Cache[K, V]) SetIfAbsent(k K, v V, size int64) (_ Handle[V], inserted, ok bool) {
+	for {
+		n, h := a.newNode(v, size)
+
+		e, inserted, ok := a.cache.SetIfAbsent(k, n, size)
+		if inserted || !ok {
+			return h, inserted, ok
+		}
+		h.Release() // n was never in the cache, so v is not released.
+
+		if eh, ok := e.Handle(); ok {
+			return eh, false, true
+		} // else already released, try again
+	}
+}
+
+// Removes expired values, releasing once unheld. See cache.Cache.RemoveExpired.
+func (a Cache[K, V]) RemoveExpired() (removed int) {
+	return a.cache.RemoveExpired()
+}
+
 func (a Cache[K, V]) Evict() (noSpace bool) {
 	return a.cache.Evict()
 }
@@ -216,6 +441,16 @@ func (a Cache[K, V]) Clear() {
 	a.cache.Clear()
 }
 
+// See cache.Cache.Subscribe.
+func (a Cache[K, V]) Subscribe(filter func(cache.Event[K]) bool) (_ <-chan cache.Event[K], cancel func()) {
+	return a.cache.Subscribe(filter)
+}
+
+// See cache.Cache.ClearFast, values release once reclaimed and unheld.
+func (a Cache[K, V]) ClearFast() (reclaimed <-chan struct{}) {
+	return a.cache.ClearFast()
+}
+
 // Intended for metrics.
 func (a Cache[K, V]) Handles() int {
 	var c int
@@ -228,6 +463,30 @@ func (a Cache[K, V]) Handles() int {
 	return c
 }
 
+// Live Handles acquired at least olderThan ago, oldest first.
+// Always empty without CacheOptions.LeakDetection.
+func (a Cache[K, V]) Leaks(olderThan time.Duration) []Leak {
+	if a.shared.leaks == nil {
+		return nil
+	}
+	return a.shared.leaks.olderThan(olderThan)
+}
+
+// Size of pinned values, included in Size.
+func (a Cache[K, V]) PinnedSize() int64 {
+	return a.cache.PinnedSize()
+}
+
+// Evicted values still held by Handles. Intended for metrics.
+func (a Cache[K, V]) ZombieLen() int {
+	return int(a.shared.zombieLen.Load())
+}
+
+// Size of evicted values still held by Handles, not included in Size.
+func (a Cache[K, V]) ZombieSize() int64 {
+	return a.shared.zombieSize.Load()
+}
+
 func (a Cache[K, V]) Capacity() int64 {
 	return a.cache.Capacity()
 }
@@ -240,11 +499,44 @@ func (a Cache[K, V]) SwapCapacity(old, new int64) (swapped bool) {
 	return a.cache.SwapCapacity(old, new)
 }
 
+// Unlimited when <= 0.
+func (a Cache[K, V]) MaxEntries() int64 {
+	return a.cache.MaxEntries()
+}
+
+// new <= 0 for unlimited.
+func (a Cache[K, V]) SetMaxEntries(new int64) (old int64) {
+	return a.cache.SetMaxEntries(new)
+}
+
+// new <= 0 for unlimited.
+func (a Cache[K, V]) SwapMaxEntries(old, new int64) (swapped bool) {
+	return a.cache.SwapMaxEntries(old, new)
+}
+
 // available (+/-) should not consider taken space in cache.
 func (a Cache[K, V]) SetAvailableCapacity(available, max int64) {
 	a.cache.SetAvailableCapacity(available, max)
 }
 
+// Waits for pending Releases when using CacheOptions.ReleaseWorkers.
+func (a Cache[K, V]) Drain() {
+	if a.shared.release != nil {
+		a.shared.release.drain()
+	}
+}
+
+// Drains and stops CacheOptions.ReleaseWorkers, later Releases run on the caller.
+// Idempotent.
+func (a Cache[K, V]) Close() {
+	if a.shared.release != nil {
+		a.shared.release.close()
+	}
+}
+
 func (a Cache[K, V]) Stats() map[string]any {
-	return a.cache.Stats()
+	s := a.cache.Stats()
+	s["zombieLen"] = a.ZombieLen()
+	s["zombieSize"] = a.ZombieSize()
+	return s
 }
@@ -1,10 +1,16 @@
 package counting_test
 
 import (
+	"runtime"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/graxinc/cache"
+	"github.com/graxinc/cache/clock/clocktest"
 	"github.com/graxinc/cache/counting"
 )
 
@@ -172,6 +178,23 @@ func TestCache_sizer(t *testing.T) {
 	}
 }
 
+func TestCache_sizerOption(t *testing.T) {
+	t.Parallel()
+
+	sizer := func(k int, v *releaseVal) int64 {
+		return int64(k * 2)
+	}
+	o := counting.CacheOptions[int, *releaseVal]{Capacity: 99, Sizer: sizer}
+	c := counting.NewCache(o)
+
+	c.Set(1, &releaseVal{}).Release()
+	c.Set(3, &releaseVal{}).Release()
+
+	if v := c.Size(); v != 8 {
+		t.Fatal(v)
+	}
+}
+
 func TestCache_evict(t *testing.T) {
 	t.Parallel()
 
@@ -371,6 +394,550 @@ func TestCache_evictSkip_noSpace(t *testing.T) {
 	}
 }
 
+func TestCache_SetIfAbsent(t *testing.T) {
+	t.Parallel()
+
+	o := counting.CacheOptions[int, *releaseVal]{Capacity: 10}
+	c := counting.NewCache(o)
+
+	v1 := &releaseVal{}
+	h1, inserted, ok := c.SetIfAbsent(1, v1, 1)
+	if !inserted || !ok || h1.Value() != v1 {
+		t.Fatal(inserted, ok)
+	}
+
+	v2 := &releaseVal{}
+	h2, inserted, ok := c.SetIfAbsent(1, v2, 1)
+	if inserted || !ok || h2.Value() != v1 {
+		t.Fatal(inserted, ok)
+	}
+
+	h1.Release()
+	h2.Release()
+
+	if r := v1.releases(); r != 0 {
+		t.Fatal(r)
+	}
+	if r := v2.releases(); r != 0 {
+		t.Fatal("candidate is the caller's to release", r)
+	}
+	if v := c.Handles(); v != 0 {
+		t.Fatal(v)
+	}
+}
+
+func TestCache_SetIfAbsent_rejected(t *testing.T) {
+	t.Parallel()
+
+	o := counting.CacheOptions[int, *releaseVal]{Capacity: 2, EvictSkip: true}
+	c := counting.NewCache(o)
+
+	// full and all held, so skipped.
+	h1 := c.Set(1, &releaseVal{})
+	h2 := c.Set(2, &releaseVal{})
+	defer h1.Release()
+	defer h2.Release()
+
+	for range 2 {
+		v := &releaseVal{}
+		h, inserted, ok := c.SetIfAbsent(3, v, 1)
+		if inserted || ok || h.Value() != v {
+			t.Fatal(inserted, ok)
+		}
+		if _, ok := c.Peek(3); ok {
+			t.Fatal("expected missing")
+		}
+
+		h.Release()
+		if r := v.releases(); r != 1 {
+			t.Fatal(r)
+		}
+	}
+
+	// set while rejecting.
+	var c2 counting.Cache[int, *releaseVal]
+	set := &releaseVal{}
+	o = counting.CacheOptions[int, *releaseVal]{
+		Capacity: 10,
+		Admit: func(k int, v *releaseVal, _ int64) bool {
+			if v != set {
+				c2.Set(k, set).Release()
+			}
+			return v == set
+		},
+	}
+	c2 = counting.NewCache(o)
+
+	v := &releaseVal{}
+	h, inserted, ok := c2.SetIfAbsent(1, v, 1)
+	if inserted || ok || h.Value() != v {
+		t.Fatal(inserted, ok)
+	}
+	h.Release()
+	if r := v.releases(); r != 1 {
+		t.Fatal(r)
+	}
+	if r := set.releases(); r != 0 {
+		t.Fatal(r)
+	}
+}
+
+func TestCache_expireAfterAccess(t *testing.T) {
+	t.Parallel()
+
+	clk := clocktest.NewFake(time.Unix(100, 0))
+	o := counting.CacheOptions[int, *releaseVal]{
+		Capacity:          10,
+		Expiration:        time.Second,
+		ExpireAfterAccess: true,
+		Clock:             clk,
+	}
+	c := counting.NewCache(o)
+
+	c.Set(1, &releaseVal{}).Release()
+	c.Set(2, &releaseVal{}).Release()
+
+	clk.Advance(900 * time.Millisecond)
+	h, ok := c.Get(1)
+	if !ok {
+		t.Fatal("expected ok")
+	}
+	h.Release()
+	if !c.Touch(2) {
+		t.Fatal("expected touched")
+	}
+
+	clk.Advance(900 * time.Millisecond)
+	for _, k := range []int{1, 2} {
+		h, ok := c.Peek(k)
+		if !ok {
+			t.Fatal("expected ok", k)
+		}
+		h.Release()
+	}
+
+	clk.Advance(100 * time.Millisecond)
+	if _, ok := c.Peek(1); ok {
+		t.Fatal("expected not ok")
+	}
+}
+
+func TestCache_RemoveExpired(t *testing.T) {
+	t.Parallel()
+
+	clk := clocktest.NewFake(time.Unix(100, 0))
+	o := counting.CacheOptions[int, *releaseVal]{
+		Capacity:        10,
+		Expiration:      time.Second,
+		ExpirationIndex: true,
+		Clock:           clk,
+	}
+	c := counting.NewCache(o)
+
+	v1, v2 := &releaseVal{}, &releaseVal{}
+	c.Set(1, v1).Release()
+	h := c.Set(2, v2)
+
+	clk.Advance(time.Second)
+	if r := c.RemoveExpired(); r != 2 {
+		t.Fatal(r)
+	}
+	if r := v1.releases(); r != 1 {
+		t.Fatal(r)
+	}
+	if r := v2.releases(); r != 0 {
+		t.Fatal("should not release while held", r)
+	}
+
+	h.Release()
+	if r := v2.releases(); r != 1 {
+		t.Fatal(r)
+	}
+}
+
+func TestCache_InvalidateTag(t *testing.T) {
+	t.Parallel()
+
+	c := counting.NewCache(counting.CacheOptions[int, *releaseVal]{Capacity: 10})
+
+	v1, v2, v3 := &releaseVal{}, &releaseVal{}, &releaseVal{}
+	c.SetST(1, v1, 1, "x").Release()
+	h := c.SetST(2, v2, 1, "x")
+	c.SetS(3, v3, 1).Release()
+
+	if r := c.InvalidateTag("x"); r != 2 {
+		t.Fatal(r)
+	}
+	if r := v1.releases(); r != 1 {
+		t.Fatal(r)
+	}
+	if r := v2.releases(); r != 0 {
+		t.Fatal("should not release while held", r)
+	}
+	h.Release()
+	if r := v2.releases(); r != 1 {
+		t.Fatal(r)
+	}
+
+	if !c.Delete(3) || c.Delete(3) {
+		t.Fatal("expected a single delete")
+	}
+	if r := v3.releases(); r != 1 {
+		t.Fatal(r)
+	}
+}
+
+func TestCache_DeleteFunc(t *testing.T) {
+	t.Parallel()
+
+	c := counting.NewCache(counting.CacheOptions[int, *releaseVal]{Capacity: 10})
+
+	vals := make([]*releaseVal, 4)
+	var held []counting.Handle[*releaseVal]
+	for i := range vals {
+		vals[i] = &releaseVal{}
+		h := c.Set(i, vals[i])
+		if i%2 == 0 {
+			held = append(held, h)
+		} else {
+			h.Release()
+		}
+	}
+
+	if r := c.DeleteFunc(func(k int, _ *releaseVal) bool { return k < 2 }); r != 2 {
+		t.Fatal(r)
+	}
+	if r := vals[0].releases(); r != 0 {
+		t.Fatal("should not release while held", r)
+	}
+	if r := vals[1].releases(); r != 1 {
+		t.Fatal(r)
+	}
+
+	for _, h := range held {
+		h.Release()
+	}
+	if r := vals[0].releases(); r != 1 {
+		t.Fatal(r)
+	}
+	if r := vals[2].releases(); r != 0 {
+		t.Fatal("still cached", r)
+	}
+}
+
+func TestCache_ClearFast(t *testing.T) {
+	t.Parallel()
+
+	c := counting.NewCache(counting.CacheOptions[int, *releaseVal]{Capacity: 10})
+
+	v1, v2 := &releaseVal{}, &releaseVal{}
+	c.Set(1, v1).Release()
+	h := c.Set(2, v2)
+
+	<-c.ClearFast()
+
+	if r := v1.releases(); r != 1 {
+		t.Fatal(r)
+	}
+	if r := v2.releases(); r != 0 {
+		t.Fatal("should not release while held", r)
+	}
+	h.Release()
+	if r := v2.releases(); r != 1 {
+		t.Fatal(r)
+	}
+	if l := c.Len(); l != 0 {
+		t.Fatal(l)
+	}
+}
+
+func TestCache_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	c := counting.NewCache(counting.CacheOptions[int, *releaseVal]{Capacity: 10})
+
+	events, cancel := c.Subscribe(nil)
+	defer cancel()
+
+	c.Set(1, &releaseVal{}).Release()
+	c.Delete(1)
+
+	if e := <-events; e.Kind != cache.EventSet || e.Key != 1 {
+		t.Fatal(e)
+	}
+	if e := <-events; e.Kind != cache.EventDelete || e.Reason != cache.ReasonDeleted {
+		t.Fatal(e)
+	}
+}
+
+func TestCache_Pin(t *testing.T) {
+	t.Parallel()
+
+	c := counting.NewCache(counting.CacheOptions[int, *releaseVal]{Capacity: 2})
+
+	v := &releaseVal{}
+	h, pinned := c.SetPinned(1, v, 1)
+	h.Release()
+	if !pinned {
+		t.Fatal("expected pinned")
+	}
+	for i := 2; i < 10; i++ {
+		c.Set(i, &releaseVal{}).Release()
+	}
+	if r := v.releases(); r != 0 {
+		t.Fatal("should not evict pinned", r)
+	}
+	if s := c.PinnedSize(); s != 1 {
+		t.Fatal(s)
+	}
+
+	if !c.Unpin(1) {
+		t.Fatal("expected unpinned")
+	}
+	c.Set(10, &releaseVal{}).Release()
+	c.Set(11, &releaseVal{}).Release()
+	if r := v.releases(); r != 1 {
+		t.Fatal(r)
+	}
+}
+
+func TestCache_SetP(t *testing.T) {
+	t.Parallel()
+
+	c := counting.NewCache(counting.CacheOptions[int, *releaseVal]{Capacity: 2, Priorities: 2})
+
+	high, low := &releaseVal{}, &releaseVal{}
+	c.SetP(1, high, 1, 1).Release()
+	c.SetP(2, low, 1, 0).Release()
+	c.Set(3, &releaseVal{}).Release()
+
+	if r := low.releases(); r != 1 {
+		t.Fatal(r)
+	}
+	if r := high.releases(); r != 0 {
+		t.Fatal("should evict lower first", r)
+	}
+}
+
+func TestCache_admit(t *testing.T) {
+	t.Parallel()
+
+	o := counting.CacheOptions[int, *releaseVal]{Capacity: 10, AdmitMaxSize: 5}
+	c := counting.NewCache(o)
+
+	v := &releaseVal{}
+	h := c.SetS(1, v, 6)
+	if _, ok := c.Get(1); ok {
+		t.Fatal("expected rejected")
+	}
+	if r := v.releases(); r != 0 {
+		t.Fatal("should not release while held", r)
+	}
+
+	h.Release()
+	if r := v.releases(); r != 1 {
+		t.Fatal(r)
+	}
+}
+
+func TestCache_zombies(t *testing.T) {
+	t.Parallel()
+
+	o := counting.CacheOptions[int, *releaseVal]{Capacity: 4}
+	c := counting.NewCache(o)
+
+	h1 := c.SetS(1, &releaseVal{}, 2)
+	c.SetS(2, &releaseVal{}, 2).Release()
+	c.SetS(3, &releaseVal{}, 2).Release() // evicts 1, held
+	c.SetS(4, &releaseVal{}, 2).Release() // evicts 2, not held
+
+	checkZombies := func(length int, size int64) {
+		t.Helper()
+		if v := c.ZombieLen(); v != length {
+			t.Fatal(v)
+		}
+		if v := c.ZombieSize(); v != size {
+			t.Fatal(v)
+		}
+	}
+	checkZombies(1, 2)
+
+	h1.Release()
+
+	checkZombies(0, 0)
+	if v := c.Size(); v != 4 {
+		t.Fatal(v)
+	}
+}
+
+func TestCache_zombies_entrySize(t *testing.T) {
+	t.Parallel()
+
+	o := counting.CacheOptions[int, *releaseVal]{Capacity: 1 << 50, EntryOverhead: true}
+	c := counting.NewCache(o)
+
+	// as counted by Size, with overhead and rounding.
+	for _, size := range []int64{2, 1<<40 + 1} {
+		h := c.SetS(1, &releaseVal{}, size)
+		want := c.Size()
+		if want == size {
+			t.Fatal("expected overhead", size)
+		}
+		c.Delete(1)
+
+		if v := c.ZombieSize(); v != want {
+			t.Fatal(v, want)
+		}
+		h.Release()
+	}
+}
+
+func TestCache_zombieCapacity(t *testing.T) {
+	t.Parallel()
+
+	o := counting.CacheOptions[int, *releaseVal]{Capacity: 4, ZombieCapacity: true}
+	c := counting.NewCache(o)
+
+	h1 := c.SetS(1, &releaseVal{}, 2)
+	c.SetS(2, &releaseVal{}, 2).Release()
+	c.SetS(3, &releaseVal{}, 1).Release() // evicts 1, held, then 2 since zombie counted
+
+	if v := c.Len(); v != 1 {
+		t.Fatal(v)
+	}
+	if v := c.ZombieSize(); v != 2 {
+		t.Fatal(v)
+	}
+
+	h1.Release()
+
+	c.SetS(4, &releaseVal{}, 1).Release()
+	if v := c.Len(); v != 2 {
+		t.Fatal(v)
+	}
+}
+
+func TestCache_releaseWorkers(t *testing.T) {
+	t.Parallel()
+
+	o := counting.CacheOptions[int, *blockingVal]{Capacity: 1, ReleaseWorkers: 1}
+	c := counting.NewCache(o)
+	defer c.Close()
+
+	v1 := &blockingVal{unblock: make(chan struct{})}
+	c.Set(1, v1).Release()
+	c.Set(2, &blockingVal{}).Release() // evicts 1, releasing on the worker
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		c.Drain()
+	}()
+
+	select {
+	case <-drained:
+		t.Fatal("drained while release blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(v1.unblock)
+	<-drained
+
+	if !v1.released.Load() {
+		t.Fatal("expected released")
+	}
+
+	c.Close()
+
+	v3 := &blockingVal{}
+	c.Set(3, v3).Release() // evicts 2, after close on the caller
+	c.Set(4, &blockingVal{}).Release()
+
+	if !v3.released.Load() {
+		t.Fatal("expected released")
+	}
+}
+
+func TestCache_leaks(t *testing.T) {
+	t.Parallel()
+
+	o := counting.CacheOptions[int, *releaseVal]{Capacity: 10, LeakDetection: true}
+	c := counting.NewCache(o)
+
+	c.Set(1, &releaseVal{}).Release()
+	h := c.Set(2, &releaseVal{})
+
+	leaks := c.Leaks(0)
+	if len(leaks) != 1 {
+		t.Fatal(leaks)
+	}
+	if !strings.Contains(leaks[0].Stack, "TestCache_leaks") {
+		t.Fatal(leaks[0].Stack)
+	}
+	if l := c.Leaks(time.Hour); len(l) != 0 {
+		t.Fatal(l)
+	}
+
+	h.Release()
+
+	if l := c.Leaks(0); len(l) != 0 {
+		t.Fatal(l)
+	}
+}
+
+func TestCache_leaks_garbageCollected(t *testing.T) {
+	t.Parallel()
+
+	reported := make(chan counting.Leak, 1)
+	o := counting.CacheOptions[int, *releaseVal]{
+		Capacity:      1,
+		LeakDetection: true,
+		LeakReport:    func(l counting.Leak) { reported <- l },
+		LeakRelease:   true,
+	}
+	c := counting.NewCache(o)
+
+	v := &releaseVal{}
+	func() {
+		c.Set(1, v) // leaked
+	}()
+	c.Set(2, &releaseVal{}).Release() // evicts 1
+
+	timeout := time.After(10 * time.Second)
+	for done := false; !done; {
+		runtime.GC()
+		select {
+		case l := <-reported:
+			if !strings.Contains(l.Stack, "TestCache_leaks_garbageCollected") {
+				t.Fatal(l.Stack)
+			}
+			done = true
+		case <-timeout:
+			t.Fatal("timeout")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if r := v.releases(); r != 1 {
+		t.Fatal(r)
+	}
+	if l := c.Leaks(0); len(l) != 0 {
+		t.Fatal(l)
+	}
+}
+
+type blockingVal struct {
+	unblock  chan struct{} // might be nil
+	released atomic.Bool
+}
+
+func (b *blockingVal) Release() {
+	if b.unblock != nil {
+		<-b.unblock
+	}
+	b.released.Store(true)
+}
+
 type releaseVal struct {
 	mu  sync.Mutex
 	rel int
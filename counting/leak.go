@@ -0,0 +1,91 @@
+package counting
+
+import (
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+)
+
+// A live Handle, recorded when CacheOptions.LeakDetection is set.
+type Leak struct {
+	Acquired time.Time
+	Stack    string // of the acquiring goroutine.
+}
+
+type leakRecord struct {
+	Leak
+}
+
+// Concurrent safe.
+type leakTracker struct {
+	report  func(Leak) // might be nil
+	release bool
+
+	mu   sync.Mutex
+	live map[*leakRecord]struct{}
+}
+
+func newLeakTracker(report func(Leak), release bool) *leakTracker {
+	return &leakTracker{
+		report:  report,
+		release: release,
+		live:    make(map[*leakRecord]struct{}),
+	}
+}
+
+func (t *leakTracker) track() *leakRecord {
+	r := &leakRecord{Leak{Acquired: time.Now(), Stack: string(debug.Stack())}}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.live[r] = struct{}{}
+	return r
+}
+
+func (t *leakTracker) untrack(r *leakRecord) (tracked bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, tracked = t.live[r]
+	delete(t.live, r)
+	return tracked
+}
+
+// Oldest first.
+func (t *leakTracker) olderThan(d time.Duration) []Leak {
+	t.mu.Lock()
+	var leaks []Leak
+	for r := range t.live {
+		if time.Since(r.Acquired) >= d {
+			leaks = append(leaks, r.Leak)
+		}
+	}
+	t.mu.Unlock()
+
+	slices.SortFunc(leaks, func(a, b Leak) int {
+		return a.Acquired.Compare(b.Acquired)
+	})
+	return leaks
+}
+
+// The tracker must not reference the Handle, so the finalizer can run.
+func watchHandle[T Releaser](t *leakTracker, h *handle[T]) {
+	h.leak = t.track()
+	runtime.SetFinalizer(h, func(h *handle[T]) {
+		if h.released.Load() || !t.untrack(h.leak) {
+			return
+		}
+		if t.report != nil {
+			t.report(h.leak.Leak)
+		}
+		if t.release {
+			h.Release()
+		}
+	})
+}
+
+func unwatchHandle[T Releaser](t *leakTracker, h *handle[T]) {
+	t.untrack(h.leak)
+	runtime.SetFinalizer(h, nil)
+}
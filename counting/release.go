@@ -0,0 +1,83 @@
+package counting
+
+import (
+	"sync"
+)
+
+type finisher interface {
+	finish()
+}
+
+// Runs finishes on a bounded set of goroutines, blocking submitters while the queue is full.
+// Concurrent safe.
+type releasePool struct {
+	queue   chan finisher
+	workers sync.WaitGroup
+	once    sync.Once
+
+	mu      sync.Mutex
+	cond    *sync.Cond // signals pending reaching zero.
+	pending int
+	closed  bool
+}
+
+func newReleasePool(workers, queue int) *releasePool {
+	p := &releasePool{queue: make(chan finisher, queue)}
+	p.cond = sync.NewCond(&p.mu)
+
+	for range workers {
+		p.workers.Add(1)
+		go func() {
+			defer p.workers.Done()
+			for f := range p.queue {
+				f.finish()
+				p.done()
+			}
+		}()
+	}
+	return p
+}
+
+// Runs on the caller once closed.
+func (p *releasePool) submit(f finisher) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		f.finish()
+		return
+	}
+	p.pending++
+	p.mu.Unlock()
+
+	// pending keeps close from closing the queue until received.
+	p.queue <- f
+}
+
+func (p *releasePool) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending--
+	if p.pending == 0 {
+		p.cond.Broadcast()
+	}
+}
+
+func (p *releasePool) drain() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.pending > 0 {
+		p.cond.Wait()
+	}
+}
+
+func (p *releasePool) close() {
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+
+		p.drain()
+		close(p.queue)
+		p.workers.Wait()
+	})
+}
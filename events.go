@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type EventKind uint8
+
+const (
+	EventSet     EventKind = iota // A key added by a Set.
+	EventReplace                  // A key's value replaced by a Set.
+	EventEvict                    // Evicted, expired, cleared or rejected, see Event.Reason.
+	EventDelete                   // Removed by Delete, DeleteFunc, InvalidateTag or SetAbsent.
+)
+
+func (e EventKind) String() string {
+	switch e {
+	case EventSet:
+		return "set"
+	case EventReplace:
+		return "replace"
+	case EventEvict:
+		return "evict"
+	case EventDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+type Event[K any] struct {
+	Kind   EventKind
+	Key    K
+	Reason Reason // Unset for EventSet.
+}
+
+type subscriber[K any] struct {
+	filter func(Event[K]) bool // might be nil
+
+	mu     sync.RWMutex // guards sends against close.
+	events chan Event[K]
+	closed bool
+}
+
+// Copy on write, so notifying does not lock the list.
+type subscribers[K any] struct {
+	mu    sync.Mutex
+	list  atomic.Pointer[[]*subscriber[K]]
+	drops atomic.Int64
+}
+
+func (s *subscribers[K]) add(sub *subscriber[K]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*subscriber[K]
+	if l := s.list.Load(); l != nil {
+		list = append(list, *l...)
+	}
+	list = append(list, sub)
+	s.list.Store(&list)
+}
+
+func (s *subscribers[K]) remove(sub *subscriber[K]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*subscriber[K]
+	if l := s.list.Load(); l != nil {
+		for _, e := range *l {
+			if e != sub {
+				list = append(list, e)
+			}
+		}
+	}
+	s.list.Store(&list)
+}
+
+// Never blocks, dropping the event for subscribers with full buffers.
+func (s *subscribers[K]) notify(e Event[K]) {
+	l := s.list.Load()
+	if l == nil {
+		return
+	}
+	for _, sub := range *l {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		sub.send(e, &s.drops)
+	}
+}
+
+func (s *subscriber[K]) send(e Event[K], drops *atomic.Int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+	select {
+	case s.events <- e:
+	default:
+		drops.Add(1)
+	}
+}
+
+func (s *subscriber[K]) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
+
+// Events for changes matching filter, nil for all. Events are sent without blocking the change,
+// those beyond CacheOptions.EventBuffer unreceived are dropped and counted in Stats.
+// filter might be called concurrently. cancel closes the channel.
+func (a *Cache[K, V]) Subscribe(filter func(Event[K]) bool) (_ <-chan Event[K], cancel func()) {
+	sub := &subscriber[K]{filter: filter, events: make(chan Event[K], a.eventBuffer)}
+	a.subs.add(sub)
+
+	cancel = func() {
+		a.subs.remove(sub)
+		sub.close()
+	}
+	return sub.events, cancel
+}
+
+// Evicts and notifies.
+func (a *Cache[K, V]) removed(k K, v V, r Reason) {
+	a.evict(k, v, r)
+
+	kind := EventEvict
+	switch r {
+	case ReasonReplaced:
+		kind = EventReplace
+	case ReasonDeleted, ReasonInvalidated:
+		kind = EventDelete
+	}
+	a.subs.notify(Event[K]{Kind: kind, Key: k, Reason: r})
+}
@@ -0,0 +1,144 @@
+package invalidation
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+type BatchOptions struct {
+	Interval time.Duration // Max delay of a Publish. Defaults to 10ms.
+	MaxKeys  int           // Publishes once this many keys and tags are pending. Defaults to 1000.
+	OnError  func(error)   // Called for errors of delayed publishes. Might be called concurrently.
+}
+
+// A Bus combining messages published within an interval, deduplicating keys and tags.
+// Messages of differing origins are not combined. Concurrent safe.
+type Batcher struct {
+	bus      Bus
+	interval time.Duration
+	maxKeys  int
+	report   func(error) // might be nil
+
+	mu      sync.Mutex
+	pending Message
+	seen    map[string]struct{} // of pending keys and tags, prefixed.
+	timer   *time.Timer         // nil when not pending.
+	closed  bool
+}
+
+// Caller must Close, publishing those pending.
+func NewBatcher(bus Bus, o BatchOptions) *Batcher {
+	if o.Interval <= 0 {
+		o.Interval = 10 * time.Millisecond
+	}
+	if o.MaxKeys <= 0 {
+		o.MaxKeys = 1000
+	}
+	return &Batcher{
+		bus:      bus,
+		interval: o.Interval,
+		maxKeys:  o.MaxKeys,
+		report:   o.OnError,
+		seen:     make(map[string]struct{}),
+	}
+}
+
+// Publishes after the interval, or immediately when reaching MaxKeys or once closed.
+// Errors of delayed publishes go to BatchOptions.OnError.
+func (b *Batcher) Publish(m Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return b.publish(m)
+	}
+
+	if b.timer != nil && m.Origin != b.pending.Origin {
+		if err := b.flush(); err != nil {
+			return err
+		}
+	}
+	b.pending.Origin = m.Origin
+	for _, k := range m.Keys {
+		if b.add("k" + k) {
+			b.pending.Keys = append(b.pending.Keys, k)
+		}
+	}
+	for _, t := range m.Tags {
+		if b.add("t" + t) {
+			b.pending.Tags = append(b.pending.Tags, t)
+		}
+	}
+
+	if len(b.seen) >= b.maxKeys {
+		return b.flush()
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, b.flushReport)
+	}
+	return nil
+}
+
+func (b *Batcher) Subscribe(handle func(Message)) (cancel func()) {
+	return b.bus.Subscribe(handle)
+}
+
+// Publishes those pending.
+func (b *Batcher) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flush()
+}
+
+// Publishes those pending, after which Publish is immediate.
+func (b *Batcher) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return b.flush()
+}
+
+func (b *Batcher) add(s string) (added bool) {
+	if _, ok := b.seen[s]; ok {
+		return false
+	}
+	b.seen[s] = struct{}{}
+	return true
+}
+
+func (b *Batcher) flushReport() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.flush(); err != nil && b.report != nil {
+		b.report(err)
+	}
+}
+
+// Holds mu, so publishes are ordered.
+func (b *Batcher) flush() error {
+	if b.timer == nil && len(b.seen) == 0 {
+		return nil
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	m := b.pending
+	b.pending = Message{}
+	clear(b.seen)
+
+	m.Keys = slices.Clip(m.Keys)
+	m.Tags = slices.Clip(m.Tags)
+	return b.publish(m)
+}
+
+func (b *Batcher) publish(m Message) error {
+	if err := b.bus.Publish(m); err != nil {
+		return errutil.Wrap(err)
+	}
+	return nil
+}
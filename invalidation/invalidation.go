@@ -0,0 +1,214 @@
+// Package invalidation shares key and tag invalidations between caches, such as
+// across processes, so writes in one invalidate the others.
+package invalidation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"github.com/graxinc/errutil"
+)
+
+// Invalidations of keys and tags, see Attach.
+type Message struct {
+	Origin string   `json:"origin"` // Publisher, messages from the own origin are ignored.
+	Keys   []string `json:"keys,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// Delivers published messages to all subscribers, including the publisher.
+// Concurrent safe.
+type Bus interface {
+	Publish(Message) error
+
+	// handle might be called concurrently. cancel stops further calls.
+	Subscribe(handle func(Message)) (cancel func())
+}
+
+// Copy on write, so delivering does not hold the lock.
+// Concurrent safe.
+type handlers struct {
+	mu   sync.Mutex
+	next int
+	m    map[int]func(Message)
+	list []func(Message)
+}
+
+func (h *handlers) add(f func(Message)) (cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.m == nil {
+		h.m = make(map[int]func(Message))
+	}
+	id := h.next
+	h.next++
+	h.m[id] = f
+	h.rebuild()
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.m, id)
+		h.rebuild()
+	}
+}
+
+func (h *handlers) rebuild() {
+	list := make([]func(Message), 0, len(h.m))
+	for _, f := range h.m {
+		list = append(list, f)
+	}
+	h.list = list
+}
+
+func (h *handlers) deliver(m Message) {
+	h.mu.Lock()
+	list := h.list
+	h.mu.Unlock()
+
+	for _, f := range list {
+		f(m)
+	}
+}
+
+// An in-memory Bus, delivering on the publishing goroutine. Intended for tests and
+// caches within a process.
+type Hub struct {
+	handlers handlers
+}
+
+func NewHub() *Hub {
+	return &Hub{}
+}
+
+func (h *Hub) Publish(m Message) error {
+	h.handlers.deliver(m)
+	return nil
+}
+
+func (h *Hub) Subscribe(handle func(Message)) (cancel func()) {
+	return h.handlers.add(handle)
+}
+
+// Satisfied by cache.Cache and counting.Cache.
+type Target[K any] interface {
+	Delete(K) bool
+	InvalidateTag(string) int
+}
+
+type AttachOptions[K any] struct {
+	Origin    string                  // Unique per attachment. Defaults to random.
+	EncodeKey func(K) string          // Defaults to the key for string keys, otherwise required.
+	DecodeKey func(string) (K, error) // Defaults to the key for string keys, otherwise required.
+	OnError   func(error)             // Called for undecodable keys. Might be called concurrently.
+}
+
+// A Target attached to a Bus, see Attach.
+// Concurrent safe.
+type Attached[K any] struct {
+	target Target[K]
+	bus    Bus
+	origin string
+	encode func(K) string
+	decode func(string) (K, error)
+	report func(error) // might be nil
+	cancel func()
+}
+
+// Applies invalidations from other origins on bus to target, and publishes those
+// made with Invalidate and InvalidateTags. Caller must Close.
+func Attach[K any](target Target[K], bus Bus, o AttachOptions[K]) *Attached[K] {
+	if o.Origin == "" {
+		o.Origin = randomOrigin()
+	}
+	if o.EncodeKey == nil {
+		o.EncodeKey = func(k K) string {
+			s, ok := any(k).(string)
+			if !ok {
+				panic(errutil.New(errutil.Tags{"missingEncodeKey": k}))
+			}
+			return s
+		}
+	}
+	if o.DecodeKey == nil {
+		var zero K
+		if _, ok := any(zero).(string); !ok {
+			panic(errutil.New(errutil.Tags{"missingDecodeKey": true}))
+		}
+		o.DecodeKey = func(s string) (K, error) {
+			return any(s).(K), nil //nolint:forcetypeassert
+		}
+	}
+
+	a := &Attached[K]{
+		target: target,
+		bus:    bus,
+		origin: o.Origin,
+		encode: o.EncodeKey,
+		decode: o.DecodeKey,
+		report: o.OnError,
+	}
+	a.cancel = bus.Subscribe(a.handle)
+	return a
+}
+
+func (a *Attached[K]) Origin() string {
+	return a.origin
+}
+
+// Deletes keys locally and publishes them.
+func (a *Attached[K]) Invalidate(keys ...K) error {
+	m := Message{Origin: a.origin, Keys: make([]string, 0, len(keys))}
+	for _, k := range keys {
+		a.target.Delete(k)
+		m.Keys = append(m.Keys, a.encode(k))
+	}
+	return a.publish(m)
+}
+
+// Invalidates tags locally and publishes them.
+func (a *Attached[K]) InvalidateTags(tags ...string) error {
+	for _, t := range tags {
+		a.target.InvalidateTag(t)
+	}
+	return a.publish(Message{Origin: a.origin, Tags: tags})
+}
+
+func (a *Attached[K]) publish(m Message) error {
+	if err := a.bus.Publish(m); err != nil {
+		return errutil.Wrap(err)
+	}
+	return nil
+}
+
+// Stops applying invalidations.
+func (a *Attached[K]) Close() {
+	a.cancel()
+}
+
+func (a *Attached[K]) handle(m Message) {
+	if m.Origin == a.origin {
+		return // loopback, already applied.
+	}
+	for _, s := range m.Keys {
+		k, err := a.decode(s)
+		if err != nil {
+			if a.report != nil {
+				a.report(errutil.Wrapt(err, errutil.Tags{"key": s}))
+			}
+			continue
+		}
+		a.target.Delete(k)
+	}
+	for _, t := range m.Tags {
+		a.target.InvalidateTag(t)
+	}
+}
+
+func randomOrigin() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,322 @@
+package invalidation_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graxinc/cache"
+	"github.com/graxinc/cache/invalidation"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAttach_hub(t *testing.T) {
+	t.Parallel()
+
+	hub := invalidation.NewHub()
+
+	c1 := cache.NewCache(cache.CacheOptions[string, int]{Capacity: 10})
+	c2 := cache.NewCache(cache.CacheOptions[string, int]{Capacity: 10})
+	a1 := invalidation.Attach(c1, hub, invalidation.AttachOptions[string]{})
+	defer a1.Close()
+	a2 := invalidation.Attach(c2, hub, invalidation.AttachOptions[string]{})
+	defer a2.Close()
+
+	for _, c := range []*cache.Cache[string, int]{c1, c2} {
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.SetST("c", 3, 1, "x")
+	}
+
+	if err := a1.Invalidate("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a2.InvalidateTags("x"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []*cache.Cache[string, int]{c1, c2} {
+		diffFatal(t, 1, c.Len())
+		if _, ok := c.Get("b"); !ok {
+			t.Fatal("expected b")
+		}
+	}
+
+	a2.Close()
+	c2.Set("a", 1)
+	if err := a1.Invalidate("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c2.Get("a"); !ok {
+		t.Fatal("expected detached")
+	}
+}
+
+func TestAttach_loopback(t *testing.T) {
+	t.Parallel()
+
+	hub := invalidation.NewHub()
+
+	var own, other recorder
+	a1 := invalidation.Attach[int](&own, hub, invalidation.AttachOptions[int]{
+		Origin:    "one",
+		EncodeKey: strconv.Itoa,
+		DecodeKey: strconv.Atoi,
+	})
+	defer a1.Close()
+
+	var errs []error
+	a2 := invalidation.Attach[int](&other, hub, invalidation.AttachOptions[int]{
+		EncodeKey: strconv.Itoa,
+		DecodeKey: strconv.Atoi,
+		OnError:   func(err error) { errs = append(errs, err) },
+	})
+	defer a2.Close()
+
+	if err := a1.Invalidate(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	diffFatal(t, []int{1, 2}, own.deleted()) // local only, not looped back.
+	diffFatal(t, []int{1, 2}, other.deleted())
+
+	if err := hub.Publish(invalidation.Message{Origin: "three", Keys: []string{"x", "3"}}); err != nil {
+		t.Fatal(err)
+	}
+	diffFatal(t, []int{1, 2, 3}, other.deleted())
+	var numErr *strconv.NumError
+	if len(errs) != 1 || !errors.As(errs[0], &numErr) {
+		t.Fatal(errs)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	t.Parallel()
+
+	do := func(t *testing.T, network, address string) {
+		t.Parallel()
+
+		ln, err := net.Listen(network, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		relay := invalidation.NewRelay(ln)
+		defer relay.Close()
+
+		var clients []*invalidation.Client
+		var attached []*invalidation.Attached[int]
+		var targets []*recorder
+		for range 3 {
+			cl, err := invalidation.Dial(network, relay.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cl.Close()
+
+			r := &recorder{}
+			a := invalidation.Attach[int](r, cl, invalidation.AttachOptions[int]{
+				EncodeKey: strconv.Itoa,
+				DecodeKey: strconv.Atoi,
+			})
+			defer a.Close()
+
+			clients = append(clients, cl)
+			attached = append(attached, a)
+			targets = append(targets, r)
+
+			// connected once its own message loops back.
+			looped := make(chan struct{})
+			cancel := cl.Subscribe(func(m invalidation.Message) {
+				if m.Origin == a.Origin() {
+					select {
+					case looped <- struct{}{}:
+					default:
+					}
+				}
+			})
+			if err := cl.Publish(invalidation.Message{Origin: a.Origin()}); err != nil {
+				t.Fatal(err)
+			}
+			<-looped
+			cancel()
+		}
+
+		for i, a := range attached {
+			if err := a.Invalidate(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// own locally, others from the relay.
+		for _, r := range targets {
+			waitFor(t, func() bool { return len(r.deleted()) == 3 })
+			diffFatal(t, []int{0, 1, 2}, r.deleted(), cmp.Transformer("sort", sorted))
+		}
+
+		relay.Close()
+		for _, cl := range clients {
+			<-cl.Done()
+		}
+	}
+
+	t.Run("tcp", func(t *testing.T) { do(t, "tcp", "127.0.0.1:0") })
+	t.Run("unix", func(t *testing.T) { do(t, "unix", filepath.Join(t.TempDir(), "relay.sock")) })
+}
+
+func TestRelay_slowConn(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "relay.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay := invalidation.NewRelay(ln)
+	defer relay.Close()
+
+	// never reads.
+	slow, err := net.Dial("unix", relay.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+
+	cl, err := invalidation.Dial("unix", relay.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	received := make(chan struct{}, 1)
+	cl.Subscribe(func(invalidation.Message) { received <- struct{}{} })
+
+	// each received before the next, so only the slow queue fills.
+	keys := slices.Repeat([]string{strings.Repeat("k", 1000)}, 32)
+	start := time.Now()
+	for range 1000 {
+		if err := cl.Publish(invalidation.Message{Origin: "o", Keys: keys}); err != nil {
+			t.Fatal(err)
+		}
+		<-received
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatal("delayed by the slow conn", d)
+	}
+
+	// dropped, reading what was written before.
+	if err := slow.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, slow); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	t.Parallel()
+
+	hub := invalidation.NewHub()
+
+	var mu sync.Mutex
+	var got []invalidation.Message
+	hub.Subscribe(func(m invalidation.Message) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, m)
+	})
+	messages := func() []invalidation.Message {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]invalidation.Message(nil), got...)
+	}
+
+	b := invalidation.NewBatcher(hub, invalidation.BatchOptions{Interval: time.Hour, MaxKeys: 4})
+	defer b.Close()
+
+	publish := func(m invalidation.Message) {
+		t.Helper()
+		if err := b.Publish(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	publish(invalidation.Message{Origin: "o", Keys: []string{"a", "b"}})
+	publish(invalidation.Message{Origin: "o", Keys: []string{"b"}, Tags: []string{"b"}})
+	diffFatal(t, 0, len(messages()))
+
+	// differing origin publishes those pending.
+	publish(invalidation.Message{Origin: "p", Keys: []string{"c"}})
+	want := []invalidation.Message{{Origin: "o", Keys: []string{"a", "b"}, Tags: []string{"b"}}}
+	diffFatal(t, want, messages())
+
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, invalidation.Message{Origin: "p", Keys: []string{"c"}})
+	diffFatal(t, want, messages())
+
+	// MaxKeys
+	publish(invalidation.Message{Origin: "o", Keys: []string{"a", "b", "c", "d"}})
+	want = append(want, invalidation.Message{Origin: "o", Keys: []string{"a", "b", "c", "d"}})
+	diffFatal(t, want, messages())
+
+	// interval
+	b2 := invalidation.NewBatcher(hub, invalidation.BatchOptions{Interval: time.Millisecond})
+	defer b2.Close()
+	if err := b2.Publish(invalidation.Message{Origin: "o", Keys: []string{"e"}}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(messages()) == len(want)+1 })
+}
+
+// Target recording deletes.
+type recorder struct {
+	mu   sync.Mutex
+	keys []int
+}
+
+func (r *recorder) Delete(k int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, k)
+	return true
+}
+
+func (r *recorder) InvalidateTag(string) int {
+	return 0
+}
+
+func (r *recorder) deleted() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.keys...)
+}
+
+func sorted(s []int) []int {
+	s = append([]int(nil), s...)
+	slices.Sort(s)
+	return s
+}
+
+func waitFor(t testing.TB, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func diffFatal(t testing.TB, want, got any, opts ...cmp.Option) {
+	t.Helper()
+	if d := cmp.Diff(want, got, opts...); d != "" {
+		t.Fatalf("(-want +got):\n%s", d)
+	}
+}
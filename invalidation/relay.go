@@ -0,0 +1,249 @@
+package invalidation
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+const (
+	maxLine      = 1 << 20 // of a JSON Message.
+	writeTimeout = 5 * time.Second
+	relayQueue   = 256 // lines queued per connection before dropping it as slow.
+)
+
+// Fans out each line received from a connection to all connections, including the sender.
+// Each connection is written by its own goroutine, so a slow one does not delay others.
+// Serves Clients over TCP or Unix sockets. Concurrent safe.
+type Relay struct {
+	ln     net.Listener
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	conns  map[*relayConn]struct{}
+	closed bool
+}
+
+type relayConn struct {
+	conn net.Conn
+	out  chan []byte   // lines to write, never closed.
+	done chan struct{} // closed once dropped.
+	once sync.Once
+}
+
+// Serves ln until Close.
+func NewRelay(ln net.Listener) *Relay {
+	r := &Relay{ln: ln, conns: make(map[*relayConn]struct{})}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.accept()
+	}()
+	return r
+}
+
+func (r *Relay) Addr() net.Addr {
+	return r.ln.Addr()
+}
+
+// Closes the listener and all connections, waiting for their goroutines.
+func (r *Relay) Close() error {
+	err := r.ln.Close()
+
+	r.mu.Lock()
+	r.closed = true
+	for c := range r.conns {
+		c.close()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	if err != nil {
+		return errutil.With(err)
+	}
+	return nil
+}
+
+func (r *Relay) accept() {
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return // closed
+		}
+		c := &relayConn{conn: conn, out: make(chan []byte, relayQueue), done: make(chan struct{})}
+
+		r.mu.Lock()
+		if r.closed { // accepted while closing.
+			r.mu.Unlock()
+			conn.Close()
+			return
+		}
+		r.conns[c] = struct{}{}
+		r.wg.Add(2)
+		r.mu.Unlock()
+
+		go func() {
+			defer r.wg.Done()
+			r.read(c)
+		}()
+		go func() {
+			defer r.wg.Done()
+			r.write(c)
+		}()
+	}
+}
+
+func (r *Relay) read(c *relayConn) {
+	defer r.drop(c)
+
+	s := bufio.NewScanner(c.conn)
+	s.Buffer(nil, maxLine)
+	for s.Scan() {
+		line := append(slices.Clip(s.Bytes()), '\n') // copies, the scanner reuses its buffer.
+		r.broadcast(line)
+	}
+}
+
+func (r *Relay) broadcast(line []byte) {
+	r.mu.Lock()
+	conns := make([]*relayConn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	r.mu.Unlock()
+
+	for _, c := range conns {
+		select {
+		case c.out <- line:
+		default:
+			r.drop(c) // slow, its queue is full.
+		}
+	}
+}
+
+func (r *Relay) write(c *relayConn) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case line := <-c.out:
+			if err := c.write(line); err != nil {
+				r.drop(c) // slow or gone.
+				return
+			}
+		}
+	}
+}
+
+func (r *Relay) drop(c *relayConn) {
+	r.mu.Lock()
+	delete(r.conns, c)
+	r.mu.Unlock()
+	c.close()
+}
+
+func (c *relayConn) write(line []byte) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return errutil.With(err)
+	}
+	if _, err := c.conn.Write(line); err != nil {
+		return errutil.With(err)
+	}
+	return nil
+}
+
+// Idempotent.
+func (c *relayConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// A Bus connected to a Relay. Subscribers are called on the reading goroutine.
+// Concurrent safe.
+type Client struct {
+	conn     net.Conn
+	mu       sync.Mutex // serializes writes.
+	handlers handlers
+	done     chan struct{}
+	err      error // of reading, set before done.
+}
+
+// network such as "tcp" or "unix". Caller must Close.
+func Dial(network, address string) (*Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	c := &Client{conn: conn, done: make(chan struct{})}
+	go c.read()
+	return c, nil
+}
+
+// Fails once the write takes over 5s, closing the Client as a partial line might be written.
+func (c *Client) Publish(m Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return errutil.With(err)
+	}
+	b = append(b, '\n')
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return errutil.With(err)
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		c.conn.Close()
+		return errutil.With(err)
+	}
+	return nil
+}
+
+func (c *Client) Subscribe(handle func(Message)) (cancel func()) {
+	return c.handlers.add(handle)
+}
+
+// Closed once disconnected, see Err.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Why disconnected, nil if by Close. Valid after Done.
+func (c *Client) Err() error {
+	return c.err
+}
+
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return errutil.With(err)
+	}
+	return nil
+}
+
+func (c *Client) read() {
+	defer close(c.done)
+
+	s := bufio.NewScanner(c.conn)
+	s.Buffer(nil, maxLine)
+	for s.Scan() {
+		var m Message
+		if err := json.Unmarshal(s.Bytes(), &m); err != nil {
+			c.err = errutil.With(err)
+			c.conn.Close()
+			return
+		}
+		c.handlers.deliver(m)
+	}
+	if err := s.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		c.err = errutil.With(err)
+	}
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"errors"
+
+	"github.com/graxinc/cache/maps"
+	"github.com/graxinc/errutil"
+)
+
+// Returned by Load for absent entries recorded without an error.
+var ErrNotFound = errors.New("cache: not found")
+
+// Of a key, see Lookup.
+type State uint8
+
+const (
+	StateMissing State = iota // Neither a value nor an absent entry.
+	StatePresent              // Has a value.
+	StateAbsent               // Known absent, see SetAbsent.
+)
+
+func (s State) String() string {
+	switch s {
+	case StateMissing:
+		return "missing"
+	case StatePresent:
+		return "present"
+	case StateAbsent:
+		return "absent"
+	default:
+		return "unknown"
+	}
+}
+
+// In-flight Loads by key.
+type loadMap[K, V any] interface {
+	maps.Map[K, *loadCall[V]]
+	maps.AbsentAdder[K, *loadCall[V]]
+}
+
+type loadCall[V any] struct {
+	done chan struct{}
+	v    V
+	err  error
+}
+
+// Like Get, distinguishing known absent keys, which return the error recorded by SetAbsent.
+// Promotes.
+func (a *Cache[K, V]) Lookup(k K) (_ V, _ State, err error) {
+	if v, ok := a.Get(k); ok {
+		return v, StatePresent, nil
+	}
+	if a.negative != nil {
+		if err, ok := a.negative.Get(k); ok {
+			return a.zero, StateAbsent, err
+		}
+	}
+	return a.zero, StateMissing, nil
+}
+
+// Records k as known absent until CacheOptions.NegativeExpiration, deleting a value.
+// err is optional, such as from a loader. A following Set replaces the absent entry.
+// Does nothing without CacheOptions.NegativeExpiration.
+func (a *Cache[K, V]) SetAbsent(k K, err error) {
+	if a.negative == nil {
+		return
+	}
+	a.delete(k, ReasonDeleted)
+	a.negative.Set(k, err)
+}
+
+func (a *Cache[K, V]) removeAbsent(k K) {
+	if a.negative != nil {
+		a.negative.delete(k, ReasonReplaced)
+	}
+}
+
+// Gets k, otherwise calls CacheOptions.Loader once across concurrent callers, Setting its value
+// or SetAbsent with its error. Absent entries return their error, or ErrNotFound.
+// Panics without CacheOptions.Loader.
+func (a *Cache[K, V]) Load(k K) (V, error) {
+	if v, found, err := a.loaded(k); found {
+		return v, err
+	}
+
+	if a.loader == nil {
+		panic(errutil.New(errutil.Tags{"missingLoader": k}))
+	}
+
+	c := &loadCall[V]{done: make(chan struct{}), err: errLoaderPanicked}
+	if e, exists := a.loads.AddIfAbsent(k, c); exists {
+		<-e.done
+		return e.v, e.err
+	}
+	defer func() {
+		a.loads.Delete(k) // after the Set, so later callers find it.
+		close(c.done)
+	}()
+
+	// a load might have finished since the first lookup.
+	if v, found, err := a.loaded(k); found {
+		c.v, c.err = v, err
+		return v, err
+	}
+
+	v, err := a.loader(k)
+	if err != nil {
+		c.v, c.err = a.zero, err
+		a.SetAbsent(k, err)
+		return a.zero, err
+	}
+	c.v, c.err = v, nil
+	a.Set(k, v)
+	return v, nil
+}
+
+// Lookup of a value or absent entry, with ErrNotFound for absent entries without an error.
+func (a *Cache[K, V]) loaded(k K) (_ V, found bool, _ error) {
+	v, state, err := a.Lookup(k)
+	switch state {
+	case StatePresent:
+		return v, true, nil
+	case StateAbsent:
+		if err == nil {
+			err = ErrNotFound
+		}
+		return a.zero, true, err
+	}
+	return a.zero, false, nil
+}
+
+var errLoaderPanicked = errors.New("cache: loader panicked")
@@ -17,6 +17,12 @@ type Map[K, V any] interface {
 	Delete(K) (_ V, exists bool)
 }
 
+// Optionally implemented by a Map, adding atomically.
+type AbsentAdder[K, V any] interface {
+	// Does not replace, returning the existing.
+	AddIfAbsent(K, V) (_ V, exists bool)
+}
+
 type Builtin[K comparable, V any] struct {
 	mu sync.RWMutex
 	m  map[K]V
@@ -41,6 +47,16 @@ func (m *Builtin[K, V]) Add(k K, v V) (V, bool) {
 	return ev, ok
 }
 
+func (m *Builtin[K, V]) AddIfAbsent(k K, v V) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ev, ok := m.m[k]; ok {
+		return ev, true
+	}
+	m.m[k] = v
+	return v, false
+}
+
 func (m *Builtin[K, V]) Delete(k K) (V, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -64,6 +80,10 @@ func (m *Sync[K, V]) Add(k K, v V) (V, bool) {
 	return e, loaded
 }
 
+func (m *Sync[K, V]) AddIfAbsent(k K, v V) (V, bool) {
+	return m.m.LoadOrStore(k, v)
+}
+
 func (m *Sync[K, V]) Delete(k K) (V, bool) {
 	e, loaded := m.m.LoadAndDelete(k)
 	return e, loaded
@@ -98,6 +118,10 @@ func (m Bucketed[K, V]) Add(k K, v V) (V, bool) {
 	return m.bucket(k).Add(k, v)
 }
 
+func (m Bucketed[K, V]) AddIfAbsent(k K, v V) (V, bool) {
+	return m.bucket(k).AddIfAbsent(k, v)
+}
+
 func (m Bucketed[K, V]) Delete(k K) (V, bool) {
 	return m.bucket(k).Delete(k)
 }
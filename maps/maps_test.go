@@ -24,6 +24,40 @@ func TestBucketed_random(t *testing.T) {
 	testRandom(m, t)
 }
 
+func TestBuiltin_AddIfAbsent(t *testing.T) {
+	m := maps.NewBuiltin[int, int]()
+	testAddIfAbsent(m, t)
+}
+
+func TestSync_AddIfAbsent(t *testing.T) {
+	var m maps.Sync[int, int]
+	testAddIfAbsent(&m, t)
+}
+
+func TestBucketed_AddIfAbsent(t *testing.T) {
+	m := maps.NewBucketed[int, int](0)
+	testAddIfAbsent(m, t)
+}
+
+type absentAdderMap interface {
+	maps.Map[int, int]
+	maps.AbsentAdder[int, int]
+}
+
+func testAddIfAbsent(m absentAdderMap, t *testing.T) {
+	t.Parallel()
+
+	if v, exists := m.AddIfAbsent(1, 2); exists || v != 2 {
+		t.Fatal(v, exists)
+	}
+	if v, exists := m.AddIfAbsent(1, 3); !exists || v != 2 {
+		t.Fatal(v, exists)
+	}
+	if v, ok := m.Get(1); !ok || v != 2 {
+		t.Fatal(v, ok)
+	}
+}
+
 func testRandom(m maps.Map[int, int], t *testing.T) {
 	t.Parallel()
 
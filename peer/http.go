@@ -0,0 +1,94 @@
+package peer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/graxinc/errutil"
+)
+
+// Default path of HTTPHandler and HTTPTransport.
+const DefaultBasePath = "/_peer/"
+
+// Fetches from peers serving HTTPHandler, where peers are base URLs such as "http://10.0.0.1:8080".
+type HTTPTransport struct {
+	Client   *http.Client // Defaults to http.DefaultClient.
+	BasePath string       // Defaults to DefaultBasePath.
+}
+
+func (t HTTPTransport) Fetch(ctx context.Context, peer, group, key string) ([]byte, error) {
+	base := t.BasePath
+	if base == "" {
+		base = DefaultBasePath
+	}
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	u := strings.TrimSuffix(peer, "/") + base + url.PathEscape(group) + "/" + url.PathEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errutil.New(errutil.Tags{"peer": peer, "status": resp.StatusCode, "body": string(b)})
+	}
+	return b, nil
+}
+
+// Serves groups to HTTPTransport at basePath, DefaultBasePath when "".
+func NewHTTPHandler(basePath string, groups ...*Group) http.Handler {
+	if basePath == "" {
+		basePath = DefaultBasePath
+	}
+	byName := make(map[string]*Group, len(groups))
+	for _, g := range groups {
+		byName[g.Name()] = g
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.EscapedPath(), basePath)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		escGroup, escKey, ok := strings.Cut(rest, "/")
+		if !ok {
+			http.Error(w, "missing key", http.StatusBadRequest)
+			return
+		}
+		name, err1 := url.PathUnescape(escGroup)
+		key, err2 := url.PathUnescape(escKey)
+		if err1 != nil || err2 != nil {
+			http.Error(w, "bad escaping", http.StatusBadRequest)
+			return
+		}
+
+		g, ok := byName[name]
+		if !ok {
+			http.Error(w, "unknown group", http.StatusNotFound)
+			return
+		}
+		v, err := g.Serve(r.Context(), key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(v)
+	})
+}
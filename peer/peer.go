@@ -0,0 +1,249 @@
+// Package peer shares a cache across processes, groupcache style. Each key is owned by
+// a peer chosen by consistent hashing, which loads and caches it, while other peers
+// fetch from the owner and keep a small hot cache of their own.
+package peer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/graxinc/cache"
+	"github.com/graxinc/cache/ring"
+	"github.com/graxinc/errutil"
+)
+
+// Fetches a group's key from a peer, see HTTPTransport.
+// Concurrent safe.
+type Transport interface {
+	Fetch(ctx context.Context, peer, group, key string) ([]byte, error)
+}
+
+type GroupOptions struct {
+	Name        string                                                // Identifies the group between peers.
+	Self        string                                                // This peer, as in SetPeers.
+	Getter      func(ctx context.Context, key string) ([]byte, error) // Loads owned keys. Might be called concurrently, though once per key at a time.
+	Transport   Transport                                             // Required with peers other than Self.
+	Capacity    int64                                                 // Bytes of owned keys and values. Defaults to 64MB.
+	HotCapacity int64                                                 // Bytes of keys and values fetched from peers. Defaults to Capacity/8.
+	Replicas    int                                                   // Hash points per peer. Defaults to 50.
+	LoadTimeout time.Duration                                         // Of each shared load or fetch, which outlives callers' cancellation. Defaults to 30s.
+}
+
+// Keys and values of a group, loaded by their owning peer.
+// Concurrent safe.
+type Group struct {
+	name      string
+	self      string
+	getter    func(context.Context, string) ([]byte, error)
+	transport Transport
+	replicas  int
+	timeout   time.Duration
+
+	main *cache.Cache[string, []byte] // owned.
+	hot  *cache.Cache[string, []byte] // fetched from owners.
+
+	ring    atomic.Pointer[ring.Ring]
+	peersMu sync.Mutex // serializes SetPeers.
+
+	loads flight
+	stats groupStats
+}
+
+type groupStats struct {
+	gets, hits, hotHits, loads, fetches, fetchErrors, served atomic.Int64
+}
+
+func NewGroup(o GroupOptions) *Group {
+	if o.Capacity <= 0 {
+		o.Capacity = 64 << 20
+	}
+	if o.HotCapacity <= 0 {
+		o.HotCapacity = max(1, o.Capacity/8)
+	}
+	if o.Replicas <= 0 {
+		o.Replicas = 50
+	}
+	if o.LoadTimeout <= 0 {
+		o.LoadTimeout = 30 * time.Second
+	}
+
+	sizer := func(k string, v []byte) int64 { return int64(len(k) + len(v)) }
+	g := &Group{
+		name:      o.Name,
+		self:      o.Self,
+		getter:    o.Getter,
+		transport: o.Transport,
+		replicas:  o.Replicas,
+		timeout:   o.LoadTimeout,
+		main:      cache.NewCache(cache.CacheOptions[string, []byte]{Capacity: o.Capacity, Sizer: sizer}),
+		hot:       cache.NewCache(cache.CacheOptions[string, []byte]{Capacity: o.HotCapacity, Sizer: sizer}),
+	}
+	g.ring.Store(g.newRing([]string{o.Self}))
+	return g
+}
+
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Replaces the peers owning keys, which should include Self. Owned keys now owned
+// by others are removed, as are hot keys now owned by Self.
+func (g *Group) SetPeers(peers ...string) {
+	peers = slices.Clone(peers)
+	slices.Sort(peers) // same ring on every peer.
+	peers = slices.Compact(peers)
+
+	g.peersMu.Lock()
+	defer g.peersMu.Unlock()
+
+	r := g.newRing(peers)
+	g.ring.Store(r)
+
+	g.main.DeleteFunc(func(k string, _ []byte) bool { return r.Owner(k) != g.self })
+	g.hot.DeleteFunc(func(k string, _ []byte) bool { return r.Owner(k) == g.self })
+}
+
+func (g *Group) newRing(peers []string) *ring.Ring {
+	members := make([]ring.Member, len(peers))
+	for i, p := range peers {
+		members[i] = ring.Member{Name: p}
+	}
+	return ring.New(members, ring.Options{VirtualNodes: g.replicas})
+}
+
+// The peer owning key, "" without peers.
+func (g *Group) Owner(key string) string {
+	return g.ring.Load().Owner(key)
+}
+
+// From the caches, otherwise from the owner once across concurrent callers, see
+// GroupOptions.LoadTimeout. Falls back to loading locally when the owner fails.
+// Returned values must not be modified.
+func (g *Group) Get(ctx context.Context, key string) ([]byte, error) {
+	g.stats.gets.Add(1)
+	if v, ok := g.main.Get(key); ok {
+		g.stats.hits.Add(1)
+		return v, nil
+	}
+	if v, ok := g.hot.Get(key); ok {
+		g.stats.hotHits.Add(1)
+		return v, nil
+	}
+
+	return g.loads.do(ctx, key, g.timeout, func(ctx context.Context) ([]byte, error) {
+		owner := g.Owner(key)
+		if owner == "" || owner == g.self {
+			return g.load(ctx, key)
+		}
+
+		g.stats.fetches.Add(1)
+		v, err := g.transport.Fetch(ctx, owner, g.name, key)
+		if err == nil {
+			g.hot.Set(key, v)
+			return v, nil
+		}
+		g.stats.fetchErrors.Add(1)
+		return g.load(ctx, key)
+	})
+}
+
+// For a Transport serving peers. Keys are loaded locally regardless of owner,
+// so differing peer lists do not forward in loops.
+func (g *Group) Serve(ctx context.Context, key string) ([]byte, error) {
+	g.stats.served.Add(1)
+	if v, ok := g.main.Get(key); ok {
+		return v, nil
+	}
+	return g.loads.do(ctx, key, g.timeout, func(ctx context.Context) ([]byte, error) {
+		return g.load(ctx, key)
+	})
+}
+
+func (g *Group) load(ctx context.Context, key string) ([]byte, error) {
+	if v, ok := g.main.Get(key); ok { // loaded while waiting.
+		return v, nil
+	}
+
+	g.stats.loads.Add(1)
+	v, err := g.getter(ctx, key)
+	if err != nil {
+		return nil, errutil.Wrapt(err, errutil.Tags{"group": g.name, "key": key})
+	}
+
+	if o := g.Owner(key); o == "" || o == g.self {
+		g.main.Set(key, v)
+	} else {
+		g.hot.Set(key, v) // owner failed.
+	}
+	return v, nil
+}
+
+// Intended for metrics.
+func (g *Group) Stats() map[string]any {
+	return map[string]any{
+		"gets":        g.stats.gets.Load(),
+		"hits":        g.stats.hits.Load(),
+		"hotHits":     g.stats.hotHits.Load(),
+		"loads":       g.stats.loads.Load(),
+		"fetches":     g.stats.fetches.Load(),
+		"fetchErrors": g.stats.fetchErrors.Load(),
+		"served":      g.stats.served.Load(),
+		"mainSize":    g.main.Size(),
+		"hotSize":     g.hot.Size(),
+	}
+}
+
+var errPanicked = errors.New("peer: load panicked")
+
+// Calls once per key across concurrent callers. Unlike cache.Cache.Load, callers pass
+// a ctx and waiters stop on their own, and the result is cached by the caller, as where
+// (owned or hot) depends on the fetch.
+// Concurrent safe.
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	v    []byte
+	err  error
+}
+
+// fn runs under the first caller's ctx without its cancellation, bounded by timeout, so
+// one caller canceling does not fail the others. That caller waits for fn, the others
+// until their ctx is done.
+func (f *flight) do(ctx context.Context, key string, timeout time.Duration, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+	f.mu.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.v, c.err
+		case <-ctx.Done():
+			return nil, errutil.Wrap(ctx.Err())
+		}
+	}
+	if f.calls == nil {
+		f.calls = make(map[string]*flightCall)
+	}
+	c := &flightCall{done: make(chan struct{}), err: errPanicked} // replaced unless fn panics.
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(c.done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	c.v, c.err = fn(ctx)
+	return c.v, c.err
+}
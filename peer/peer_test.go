@@ -0,0 +1,282 @@
+package peer_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/graxinc/cache/peer"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type cluster struct {
+	groups  []*peer.Group
+	servers []*httptest.Server
+	loads   atomic.Int64
+}
+
+func newCluster(t testing.TB, n int, getter func(context.Context, string) ([]byte, error)) *cluster {
+	t.Helper()
+
+	c := &cluster{}
+	counted := func(ctx context.Context, key string) ([]byte, error) {
+		c.loads.Add(1)
+		return getter(ctx, key)
+	}
+
+	var urls []string
+	for range n {
+		var handler atomic.Pointer[http.Handler]
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			(*handler.Load()).ServeHTTP(w, r)
+		}))
+		t.Cleanup(s.Close)
+
+		g := peer.NewGroup(peer.GroupOptions{
+			Name:      "g",
+			Self:      s.URL,
+			Getter:    counted,
+			Transport: peer.HTTPTransport{},
+			Capacity:  1 << 20,
+		})
+		h := peer.NewHTTPHandler("", g)
+		handler.Store(&h)
+
+		c.groups = append(c.groups, g)
+		c.servers = append(c.servers, s)
+		urls = append(urls, s.URL)
+	}
+	for _, g := range c.groups {
+		g.SetPeers(urls...)
+	}
+	return c
+}
+
+func value(_ context.Context, key string) ([]byte, error) {
+	return []byte("v" + key), nil
+}
+
+func TestGroup_Get(t *testing.T) {
+	t.Parallel()
+
+	c := newCluster(t, 3, value)
+	ctx := context.Background()
+
+	owners := map[string]int{}
+	for i := range 30 {
+		key := "k/" + strconv.Itoa(i) // escaped in paths.
+		owners[c.groups[0].Owner(key)]++
+
+		for _, g := range c.groups {
+			diffFatal(t, c.groups[0].Owner(key), g.Owner(key))
+
+			v, err := g.Get(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diffFatal(t, "v"+key, string(v))
+		}
+	}
+	if len(owners) != 3 {
+		t.Fatal(owners)
+	}
+
+	// loaded once each by its owner, others fetched then hit their hot cache.
+	diffFatal(t, int64(30), c.loads.Load())
+	for _, g := range c.groups {
+		if _, err := g.Get(ctx, "k/0"); err != nil {
+			t.Fatal(err)
+		}
+		s := g.Stats()
+		diffFatal(t, int64(30), s["loads"].(int64)+s["fetches"].(int64))
+	}
+	diffFatal(t, int64(30), c.loads.Load())
+}
+
+func TestGroup_Get_deduplicated(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	c := newCluster(t, 3, func(ctx context.Context, key string) ([]byte, error) {
+		<-release
+		return value(ctx, key)
+	})
+
+	var wg sync.WaitGroup
+	for range 5 {
+		for _, g := range c.groups {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := g.Get(context.Background(), "key"); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+	}
+	time.Sleep(20 * time.Millisecond) // let callers join the load.
+	close(release)
+	wg.Wait()
+
+	diffFatal(t, int64(1), c.loads.Load())
+}
+
+func TestGroup_Get_canceled(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := newCluster(t, 1, func(ctx context.Context, key string) ([]byte, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return value(ctx, key)
+	})
+	g := c.groups[0]
+
+	get := func(ctx context.Context) <-chan error {
+		errs := make(chan error, 1)
+		go func() {
+			_, err := g.Get(ctx, "key")
+			errs <- err
+		}()
+		return errs
+	}
+
+	// the first caller canceling does not fail the load.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := get(ctx)
+	<-started
+	second := get(context.Background())
+	cancel()
+
+	// waiters stop on their own ctx.
+	canceled, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+	if err := <-get(canceled); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+
+	close(release)
+	for _, errs := range []<-chan error{first, second} {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	diffFatal(t, int64(1), c.loads.Load())
+}
+
+func TestGroup_Get_errors(t *testing.T) {
+	t.Parallel()
+
+	errGone := errors.New("gone")
+	c := newCluster(t, 2, func(context.Context, string) ([]byte, error) {
+		return nil, errGone
+	})
+
+	for _, g := range c.groups {
+		if _, err := g.Get(context.Background(), "key"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+
+	// owner down, loaded locally.
+	c2 := newCluster(t, 2, value)
+	key := "key"
+	var other *peer.Group
+	for i, g := range c2.groups {
+		if g.Owner(key) != c2.servers[i].URL {
+			other = g
+		} else {
+			c2.servers[i].Close()
+		}
+	}
+	v, err := other.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffFatal(t, "vkey", string(v))
+	diffFatal(t, int64(1), other.Stats()["fetchErrors"])
+}
+
+func TestGroup_SetPeers(t *testing.T) {
+	t.Parallel()
+
+	c := newCluster(t, 3, value)
+	ctx := context.Background()
+
+	for i := range 100 {
+		if _, err := c.groups[0].Get(ctx, strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := c.groups[0].Stats()["mainSize"].(int64)
+	if before == 0 {
+		t.Fatal("expected owned")
+	}
+
+	// alone, everything owned, nothing hot.
+	c.groups[0].SetPeers(c.servers[0].URL)
+	diffFatal(t, int64(0), c.groups[0].Stats()["hotSize"])
+	diffFatal(t, before, c.groups[0].Stats()["mainSize"])
+
+	// others own all.
+	c.groups[0].SetPeers(c.servers[1].URL, c.servers[2].URL)
+	diffFatal(t, int64(0), c.groups[0].Stats()["mainSize"])
+
+	loads := c.loads.Load()
+	for i := range 100 {
+		key := strconv.Itoa(i)
+		if o := c.groups[0].Owner(key); o == c.servers[0].URL {
+			t.Fatal("expected others", key)
+		}
+		if _, err := c.groups[0].Get(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l := c.loads.Load() - loads; l == 100 {
+		t.Fatal("expected some still cached by their owners", l)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	t.Parallel()
+
+	g := peer.NewGroup(peer.GroupOptions{Name: "a b", Self: "self", Getter: value})
+	s := httptest.NewServer(peer.NewHTTPHandler("/x/", g))
+	defer s.Close()
+
+	tr := peer.HTTPTransport{BasePath: "/x/"}
+	v, err := tr.Fetch(context.Background(), s.URL, "a b", "c/d?e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffFatal(t, "vc/d?e", string(v))
+
+	if _, err := tr.Fetch(context.Background(), s.URL, "missing", "c"); err == nil {
+		t.Fatal("expected unknown group")
+	}
+
+	resp, err := http.Get(s.URL + "/x/a%20b") //nolint:noctx
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	diffFatal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func diffFatal(t testing.TB, want, got any, opts ...cmp.Option) {
+	t.Helper()
+	if d := cmp.Diff(want, got, opts...); d != "" {
+		t.Fatalf("(-want +got):\n%v", d)
+	}
+}
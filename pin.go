@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"iter"
+)
+
+// A pinned value's key, with the priority class to return to, see Unpin.
+type pinnedKey[K any] struct {
+	k        K
+	priority int
+}
+
+// Removes k's value from eviction, so it only leaves by replacing, deleting, expiring or
+// clearing. Expired pinned values are removed when evictions find nothing else, or by
+// RemoveExpired. Replacing Sets keep k pinned. Returns whether pinned, false when
+// missing or exceeding CacheOptions.MaxPinnedFraction.
+func (a *Cache[K, V]) Pin(k K) bool {
+	v, ok := a.get(k)
+	if !ok {
+		return false
+	}
+	a.policyMu.Lock()
+	defer a.policyMu.Unlock()
+	return a.pin(k, v)
+}
+
+// Returns k's value to eviction, returning whether it was pinned.
+func (a *Cache[K, V]) Unpin(k K) bool {
+	v, ok := a.items.Get(k)
+	if !ok {
+		return false
+	}
+
+	a.policyMu.Lock()
+	pk, ok := a.pinned[v]
+	if !ok {
+		a.policyMu.Unlock()
+		return false
+	}
+	a.unpin(v)
+	a.panicPolicyAddClass(k, pk.priority)
+	a.policyMu.Unlock()
+	return true
+}
+
+// Like SetS, also pinning the value (see Pin). Returns whether pinned.
+func (a *Cache[K, V]) SetPinned(k K, v V, size int64) (pinned bool) {
+	return a.setS(k, v, size, setOptions{pin: true})
+}
+
+// Size of pinned values, included in Size.
+func (a *Cache[K, V]) PinnedSize() int64 {
+	return a.pinnedSize.Load()
+}
+
+// Under policyMu.
+func (a *Cache[K, V]) pin(k K, v *CacheValue[V]) bool {
+	if _, ok := a.pinned[v]; ok {
+		return true
+	}
+	a.pinnedLen.Add(1) // before checking k, so replaces seeing none pinned are seen here.
+	var priority int
+	if a.classes != nil {
+		priority, _ = a.classes.Class(k)
+	}
+	size := v.loadMeta().size().int64()
+	// replaced, removed, too large or not in the policy yet.
+	if cur, ok := a.items.Get(k); !ok || cur != v || !a.pinFits(size) || !a.policyRemove(k) {
+		a.pinnedLen.Add(-1)
+		return false
+	}
+	a.pinned[v] = pinnedKey[K]{k, priority}
+	a.pinnedSize.Add(size)
+	return true
+}
+
+// Under policyMu, returns whether v was pinned.
+func (a *Cache[K, V]) unpin(v *CacheValue[V]) bool {
+	if _, ok := a.pinned[v]; !ok {
+		return false
+	}
+	delete(a.pinned, v)
+	a.pinnedSize.Add(-v.loadMeta().size().int64())
+	a.pinnedLen.Add(-1)
+	return true
+}
+
+// av replaced p, moving k to o.priority. Keeps k pinned when p was and not cleared,
+// or pins when o.pin. Returns whether pinned.
+func (a *Cache[K, V]) replaced(k K, av, p *CacheValue[V], o setOptions) bool {
+	if !o.pin && a.classes == nil && a.pinnedLen.Load() == 0 {
+		return false // p unpinned, as pins check k after counting.
+	}
+
+	a.policyMu.Lock()
+	defer a.policyMu.Unlock()
+
+	if !a.unpin(p) {
+		if a.classes != nil {
+			a.classes.SetClass(k, o.priority) // no-op until p's Set adds k, keeping p's class.
+		}
+		return o.pin && a.pin(k, av)
+	}
+
+	size := av.loadMeta().size().int64()
+	if (o.pin || !a.cleared(p)) && a.pinFits(size) {
+		a.pinned[av] = pinnedKey[K]{k, o.priority}
+		a.pinnedSize.Add(size)
+		a.pinnedLen.Add(1)
+		return true
+	}
+	a.panicPolicyAddClass(k, o.priority)
+	return false
+}
+
+// Removes expired pinned values with ReasonExpired, returning the count removed.
+func (a *Cache[K, V]) expireAllPinned() (removed int) {
+	if a.pinnedSize.Load() == 0 {
+		return 0
+	}
+
+	var es []entry[K, V]
+	a.policyMu.RLock()
+	for v, pk := range a.pinned {
+		if !a.cleared(v) && a.expired(v.loadMeta().expire()) {
+			es = append(es, entry[K, V]{pk.k, v})
+		}
+	}
+	a.policyMu.RUnlock()
+
+	for _, e := range es {
+		if a.remove(e.k, e.v, ReasonExpired) {
+			removed++
+		}
+	}
+	return removed
+}
+
+func (a *Cache[K, V]) pinFits(size int64) bool {
+	return float64(a.pinnedSize.Load()+size) <= a.maxPinnedFrac*float64(a.cap.Load())
+}
+
+// Pinned then policy keys, under policyMu.
+func (a *Cache[K, V]) keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for _, pk := range a.pinned {
+			if !yield(pk.k) {
+				return
+			}
+		}
+		for k := range a.policy.Values() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
@@ -178,6 +178,11 @@ func (c *KeyList[T]) Remove(elt *Element[T]) {
 	c.l.Remove(elt)
 }
 
+// might return nil.
+func (c *KeyList[T]) Tail() *Element[T] {
+	return c.l.Back()
+}
+
 // list must not be empty.
 func (c *KeyList[T]) RemoveTail() *Element[T] {
 	elt := c.l.Back()
@@ -28,6 +28,43 @@ type Policy[T any] interface {
 	Stats() map[string]any
 }
 
+// Optionally implemented by a Policy, see EvictSkipLimit.
+type SkipLimiter[T any] interface {
+	// Like EvictSkip, calling skip at most limit times. Unlimited when limit <= 0.
+	EvictSkipLimit(skip func(T) bool, limit int) (_ T, ok bool)
+}
+
+// Optionally implemented by a Policy, see Remove.
+type Remover[T any] interface {
+	// Unlike an eviction, not remembered by the policy.
+	Remove(T) (exists bool)
+}
+
+// p's SkipLimiter, otherwise EvictSkip skipping without calling skip once past limit.
+func EvictSkipLimit[T any](p Policy[T], skip func(T) bool, limit int) (_ T, ok bool) {
+	if l, ok := p.(SkipLimiter[T]); ok {
+		return l.EvictSkipLimit(skip, limit)
+	}
+	if limit <= 0 {
+		return p.EvictSkip(skip)
+	}
+	var calls int
+	return p.EvictSkip(func(k T) bool {
+		calls++
+		return calls > limit || skip(k)
+	})
+}
+
+// p's Remover, otherwise evicting only key with EvictSkip, which p might remember
+// or reorder by.
+func Remove[T comparable](p Policy[T], key T) (exists bool) {
+	if r, ok := p.(Remover[T]); ok {
+		return r.Remove(key)
+	}
+	_, ok := p.EvictSkip(func(k T) bool { return k != key })
+	return ok
+}
+
 type ARC[T comparable] struct {
 	t1 internal.KeyList[T]
 	t2 internal.KeyList[T]
@@ -95,9 +132,20 @@ func (c *ARC[T]) Promote(key T) bool {
 }
 
 func (c *ARC[T]) EvictSkip(skip func(T) bool) (evicted T, ok bool) {
+	return c.EvictSkipLimit(skip, 0)
+}
+
+// Skipped elements are rotated to the front of their list, so following calls
+// start from elements not yet skipped.
+func (c *ARC[T]) EvictSkipLimit(skip func(T) bool, limit int) (evicted T, ok bool) {
+	var scans int
 	tRemove := func(tList, bList internal.KeyList[T]) (T, bool) {
-		for elm := range tList.AllReverse() {
+		for n := tList.Len(); n > 0 && (limit <= 0 || scans < limit); n-- {
+			scans++
+
+			elm := tList.Tail()
 			if skip(elm.Value) {
+				tList.MoveToFront(elm)
 				continue
 			}
 			tList.Remove(elm)
@@ -158,6 +206,18 @@ func (c *ARC[T]) Add(key T) (ok bool) {
 	return true
 }
 
+func (c *ARC[T]) Remove(key T) bool {
+	if elt := c.t2.Lookup(key); elt != nil {
+		c.t2.Remove(elt)
+		return true
+	}
+	if elt := c.t1.Lookup(key); elt != nil {
+		c.t1.Remove(elt)
+		return true
+	}
+	return false
+}
+
 type ARCParams struct {
 	T1Len, T2Len     int
 	B1Len, B2Len     int
@@ -219,6 +219,62 @@ func TestARC_evictSkip(t *testing.T) {
 	diffFatal(t, want, got)
 }
 
+func TestARC_evictSkipLimit(t *testing.T) {
+	t.Parallel()
+
+	p := policy.NewARC[int]()
+
+	for i := range 5 {
+		p.Add(i)
+	}
+
+	var skipped []int
+	skip := func(k int) bool {
+		skipped = append(skipped, k)
+		return k < 3
+	}
+
+	_, ok := p.EvictSkipLimit(skip, 2)
+	diffFatal(t, false, ok)
+	diffFatal(t, []int{0, 1}, skipped)
+
+	// skipped were rotated to the front.
+	diffFatal(t, []int{1, 0, 4, 3, 2}, slices.Collect(p.Values()))
+
+	skipped = nil
+	_, ok = p.EvictSkipLimit(skip, 1)
+	diffFatal(t, false, ok)
+	diffFatal(t, []int{2}, skipped)
+
+	skipped = nil
+	e, ok := p.EvictSkipLimit(skip, 0)
+	diffFatal(t, true, ok)
+	diffFatal(t, 3, e)
+	diffFatal(t, []int{3}, skipped)
+
+	diffFatal(t, []int{2, 1, 0, 4}, slices.Collect(p.Values()))
+}
+
+func TestARC_remove(t *testing.T) {
+	t.Parallel()
+
+	p := policy.NewARC[int]()
+
+	for i := range 4 {
+		p.Add(i)
+	}
+	p.Promote(2)
+
+	diffFatal(t, true, p.Remove(1))
+	diffFatal(t, true, p.Remove(2))
+	diffFatal(t, false, p.Remove(2))
+	diffFatal(t, []int{3, 0}, slices.Collect(p.Values()))
+
+	// not remembered, so a re-add is recent.
+	p.Add(2)
+	diffFatal(t, policy.ARCParams{T1Len: 3}, p.ARCParams())
+}
+
 func TestARC_values_order(t *testing.T) {
 	t.Parallel()
 
@@ -479,3 +535,142 @@ func diffFatal(t testing.TB, want, got any, opts ...cmp.Option) {
 		t.Fatalf("(-want +got):\n%v", d)
 	}
 }
+
+func TestPriority_evict(t *testing.T) {
+	t.Parallel()
+
+	var p policy.Policy[int] = policy.NewPriority[int](3, nil, nil)
+	pr := p.(*policy.Priority[int])
+
+	p.Add(1)
+	p.Add(2)
+	pr.AddClass(10, 1)
+	pr.AddClass(11, 1)
+	pr.AddClass(12, 1)
+	pr.AddClass(20, 2)
+	pr.AddClass(21, 9) // clamped
+
+	diffFatal(t, []int{21, 20, 12, 11, 10, 2, 1}, slices.Collect(p.Values()))
+
+	// per weight: class 0 2/1, class 1 3/2, class 2 2/4.
+	var evicted []int
+	for range 7 {
+		k, ok := p.Evict()
+		if !ok {
+			t.Fatal("expected evict")
+		}
+		evicted = append(evicted, k)
+	}
+	diffFatal(t, []int{1, 10, 2, 11, 12, 20, 21}, evicted)
+
+	_, ok := p.Evict()
+	diffFatal(t, false, ok)
+
+	s := p.Stats()["classes"].([]map[string]any)
+	diffFatal(t, int64(2), s[0]["evictions"])
+	diffFatal(t, 4.0, s[2]["weight"])
+	diffFatal(t, 0, s[2]["len"])
+}
+
+func TestPriority_evictSkipLimit(t *testing.T) {
+	t.Parallel()
+
+	p := policy.NewPriority[int](2, []float64{1, 100}, nil)
+	p.Add(1)
+	p.Add(2)
+	p.AddClass(10, 1)
+
+	var skipped []int
+	skip := func(k int) bool {
+		skipped = append(skipped, k)
+		return k < 10
+	}
+
+	_, ok := p.EvictSkipLimit(skip, 2)
+	diffFatal(t, false, ok)
+	diffFatal(t, []int{1, 2}, skipped)
+
+	skipped = nil
+	e, ok := p.EvictSkip(skip)
+	diffFatal(t, true, ok)
+	diffFatal(t, 10, e)
+	diffFatal(t, []int{1, 2, 10}, skipped)
+}
+
+// Hides the optional interfaces.
+type basicPolicy struct {
+	policy.Policy[int]
+}
+
+func TestEvictSkipLimit_fallback(t *testing.T) {
+	t.Parallel()
+
+	p := basicPolicy{policy.NewARC[int]()}
+	for i := range 5 {
+		p.Add(i)
+	}
+
+	var skipped []int
+	skip := func(k int) bool {
+		skipped = append(skipped, k)
+		return k < 3
+	}
+
+	_, ok := policy.EvictSkipLimit[int](p, skip, 2)
+	diffFatal(t, false, ok)
+	diffFatal(t, []int{0, 1}, skipped)
+
+	skipped = nil
+	e, ok := policy.EvictSkipLimit[int](p, skip, 0)
+	diffFatal(t, true, ok)
+	diffFatal(t, 3, e)
+}
+
+func TestRemove_fallback(t *testing.T) {
+	t.Parallel()
+
+	p := basicPolicy{policy.NewARC[int]()}
+	for i := range 4 {
+		p.Add(i)
+	}
+
+	diffFatal(t, true, policy.Remove[int](p, 2))
+	diffFatal(t, false, policy.Remove[int](p, 2))
+	diffFatal(t, []int{0, 1, 3}, slices.Sorted(p.Values())) // reordered by skipping.
+
+	// Priority uses it for its classes.
+	pr := policy.NewPriority(2, nil, func() policy.Policy[int] { return basicPolicy{policy.NewARC[int]()} })
+	pr.AddClass(1, 1)
+	diffFatal(t, true, pr.Remove(1))
+	diffFatal(t, []int(nil), slices.Collect(pr.Values()))
+}
+
+func TestPriority_SetClass(t *testing.T) {
+	t.Parallel()
+
+	p := policy.NewPriority[int](2, nil, nil)
+
+	diffFatal(t, false, p.SetClass(1, 1))
+	diffFatal(t, true, p.Add(1))
+	diffFatal(t, false, p.AddClass(1, 1))
+	p.Add(2)
+	diffFatal(t, true, p.SetClass(1, 1))
+
+	c, ok := p.Class(1)
+	diffFatal(t, true, ok)
+	diffFatal(t, 1, c)
+	diffFatal(t, true, p.Promote(1))
+
+	k, _ := p.Evict()
+	diffFatal(t, 2, k)
+
+	diffFatal(t, true, p.Remove(1))
+	diffFatal(t, false, p.Remove(1))
+	_, ok = p.Class(1)
+	diffFatal(t, false, ok)
+
+	p.AddClass(3, 1)
+	p.Clear()
+	diffFatal(t, 0, len(slices.Collect(p.Values())))
+	diffFatal(t, true, p.Add(3))
+}
@@ -0,0 +1,206 @@
+package policy
+
+import (
+	"iter"
+	"math"
+	"slices"
+)
+
+// Priority classes, each a separate Policy. Evicts from the class with the most values
+// per weight, so lower classes, having lower weights, are evicted first while not
+// starving higher classes that grow past their share. Add uses class 0, see AddClass.
+type Priority[T comparable] struct {
+	classes []priorityClass[T]
+	keys    map[T]int // class by key
+	order   []int     // reused by evictOrder.
+}
+
+type priorityClass[T any] struct {
+	policy    Policy[T]
+	weight    float64
+	len       int
+	evictions int64
+}
+
+// Class i weighs weights[i], defaulting to 2^i. create defaults to NewARC.
+// At least 1 class.
+func NewPriority[T comparable](classes int, weights []float64, create func() Policy[T]) *Priority[T] {
+	if create == nil {
+		create = func() Policy[T] { return NewARC[T]() }
+	}
+	classes = max(1, classes)
+
+	p := &Priority[T]{classes: make([]priorityClass[T], classes), keys: make(map[T]int)}
+	for i := range p.classes {
+		w := math.Pow(2, float64(i))
+		if i < len(weights) && weights[i] > 0 {
+			w = weights[i]
+		}
+		p.classes[i] = priorityClass[T]{policy: create(), weight: w}
+	}
+	return p
+}
+
+func (p *Priority[T]) Classes() int {
+	return len(p.classes)
+}
+
+func (p *Priority[T]) Clear() {
+	for i := range p.classes {
+		p.classes[i].policy.Clear()
+		p.classes[i].len = 0
+	}
+	clear(p.keys)
+}
+
+func (p *Priority[T]) Promote(key T) bool {
+	c, ok := p.keys[key]
+	return ok && p.classes[c].policy.Promote(key)
+}
+
+func (p *Priority[T]) Evict() (_ T, ok bool) {
+	for _, c := range p.evictOrder() {
+		if k, ok := p.classes[c].policy.Evict(); ok {
+			p.evicted(k, c)
+			return k, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func (p *Priority[T]) EvictSkip(skip func(T) bool) (_ T, ok bool) {
+	return p.EvictSkipLimit(skip, 0)
+}
+
+// limit is across classes.
+func (p *Priority[T]) EvictSkipLimit(skip func(T) bool, limit int) (_ T, ok bool) {
+	var calls int
+	counted := func(k T) bool {
+		calls++
+		return skip(k)
+	}
+	for _, c := range p.evictOrder() {
+		remaining := 0
+		if limit > 0 {
+			remaining = limit - calls
+			if remaining <= 0 {
+				break
+			}
+		}
+		if k, ok := EvictSkipLimit(p.classes[c].policy, counted, remaining); ok {
+			p.evicted(k, c)
+			return k, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Adds to class 0.
+func (p *Priority[T]) Add(key T) bool {
+	return p.AddClass(key, 0)
+}
+
+// class is clamped to the classes. !ok if already exists.
+func (p *Priority[T]) AddClass(key T, class int) (ok bool) {
+	if _, ok := p.keys[key]; ok {
+		return false
+	}
+	class = p.clamp(class)
+	if !p.classes[class].policy.Add(key) {
+		return false
+	}
+	p.keys[key] = class
+	p.classes[class].len++
+	return true
+}
+
+// Moves an existing key to class, clamped to the classes.
+func (p *Priority[T]) SetClass(key T, class int) (exists bool) {
+	c, ok := p.keys[key]
+	if !ok {
+		return false
+	}
+	class = p.clamp(class)
+	if c == class {
+		return true
+	}
+	p.Remove(key)
+	p.AddClass(key, class)
+	return true
+}
+
+func (p *Priority[T]) Class(key T) (_ int, exists bool) {
+	c, ok := p.keys[key]
+	return c, ok
+}
+
+func (p *Priority[T]) Remove(key T) bool {
+	c, ok := p.keys[key]
+	if !ok {
+		return false
+	}
+	delete(p.keys, key)
+	Remove(p.classes[c].policy, key)
+	p.classes[c].len--
+	return true
+}
+
+// Highest class first, each hottest to coldest.
+func (p *Priority[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := len(p.classes) - 1; i >= 0; i-- {
+			for k := range p.classes[i].policy.Values() {
+				if !yield(k) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *Priority[T]) Stats() map[string]any {
+	classes := make([]map[string]any, len(p.classes))
+	for i, c := range p.classes {
+		classes[i] = map[string]any{
+			"len":       c.len,
+			"weight":    c.weight,
+			"evictions": c.evictions,
+			"policy":    c.policy.Stats(),
+		}
+	}
+	return map[string]any{"classes": classes}
+}
+
+func (p *Priority[T]) clamp(class int) int {
+	return min(max(class, 0), len(p.classes)-1)
+}
+
+func (p *Priority[T]) evicted(k T, class int) {
+	delete(p.keys, k)
+	p.classes[class].len--
+	p.classes[class].evictions++
+}
+
+// Non-empty classes by most values per weight, then lowest class.
+func (p *Priority[T]) evictOrder() []int {
+	p.order = p.order[:0]
+	for i, c := range p.classes {
+		if c.len > 0 {
+			p.order = append(p.order, i)
+		}
+	}
+	slices.SortStableFunc(p.order, func(a, b int) int {
+		sa := float64(p.classes[a].len) / p.classes[a].weight
+		sb := float64(p.classes[b].len) / p.classes[b].weight
+		switch {
+		case sa > sb:
+			return -1
+		case sa < sb:
+			return 1
+		}
+		return 0
+	})
+	return p.order
+}
@@ -0,0 +1,247 @@
+// Package ring maps keys to members by consistent hashing, with virtual nodes, weights,
+// bounded loads and rendezvous hashing. Lookups do not allocate.
+package ring
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"sync/atomic"
+)
+
+type Member struct {
+	Name   string
+	Weight int // Relative share of keys. Defaults to 1.
+}
+
+// Maps keys to members, see Ring and Rendezvous.
+// Concurrent safe.
+type Hasher interface {
+	// "" without members.
+	Owner(key string) string
+}
+
+type Options struct {
+	VirtualNodes int // Points per unit of Weight. Defaults to 100.
+}
+
+// A consistent hash ring, where adding or removing a member moves only its share of keys.
+// Immutable, so concurrent safe.
+type Ring struct {
+	members []Member
+	points  []point // sorted by hash.
+	index   map[string]int
+}
+
+type point struct {
+	hash   uint64
+	member int
+}
+
+// Duplicate names are combined, the first weight used.
+func New(members []Member, o Options) *Ring {
+	if o.VirtualNodes <= 0 {
+		o.VirtualNodes = 100
+	}
+
+	r := &Ring{index: make(map[string]int, len(members))}
+	for _, m := range members {
+		if _, ok := r.index[m.Name]; ok {
+			continue
+		}
+		m.Weight = max(1, m.Weight)
+		i := len(r.members)
+		r.index[m.Name] = i
+		r.members = append(r.members, m)
+
+		for v := range o.VirtualNodes * m.Weight {
+			r.points = append(r.points, point{hashString(m.Name + "#" + strconv.Itoa(v)), i})
+		}
+	}
+	slices.SortFunc(r.points, func(a, b point) int {
+		if a.hash != b.hash {
+			if a.hash < b.hash {
+				return -1
+			}
+			return 1
+		}
+		return a.member - b.member // collisions ordered by member, independent of input order.
+	})
+	return r
+}
+
+func (r *Ring) Members() []Member {
+	return slices.Clone(r.members)
+}
+
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	return r.members[r.points[r.search(hashString(key))].member].Name
+}
+
+// Index of the first point at or after h, wrapping.
+func (r *Ring) search(h uint64) int {
+	i, _ := slices.BinarySearchFunc(r.points, h, func(p point, h uint64) int {
+		switch {
+		case p.hash < h:
+			return -1
+		case p.hash > h:
+			return 1
+		}
+		return 0
+	})
+	if i == len(r.points) {
+		return 0
+	}
+	return i
+}
+
+// Bounds member loads to a factor of their weighted share, walking the ring past
+// full members. Loads are acquired per key, such as per connection or request.
+// Concurrent safe.
+type Bounded struct {
+	ring   *Ring
+	factor float64
+	weight int // total
+	loads  []atomic.Int64
+	total  atomic.Int64
+}
+
+// factor above 1, such as 1.25, defaults to 1.25.
+func NewBounded(r *Ring, factor float64) *Bounded {
+	if factor <= 1 {
+		factor = 1.25
+	}
+	b := &Bounded{ring: r, factor: factor, loads: make([]atomic.Int64, len(r.members))}
+	for _, m := range r.members {
+		b.weight += m.Weight
+	}
+	return b
+}
+
+// The owner of key with load under its bound, which must be Released. "" without members.
+func (b *Bounded) Acquire(key string) string {
+	r := b.ring
+	if len(r.points) == 0 {
+		return ""
+	}
+	total := b.total.Add(1)
+
+	start := r.search(hashString(key))
+	for n := range len(r.points) {
+		i := r.points[(start+n)%len(r.points)].member
+		if b.tryAcquire(i, total) {
+			return r.members[i].Name
+		}
+	}
+	// unreachable as bounds sum above total, though concurrent acquires might race.
+	i := r.points[start].member
+	b.loads[i].Add(1)
+	return r.members[i].Name
+}
+
+func (b *Bounded) tryAcquire(i int, total int64) bool {
+	share := float64(b.ring.members[i].Weight) / float64(b.weight)
+	bound := int64(math.Ceil(b.factor * share * float64(total)))
+	for {
+		l := b.loads[i].Load()
+		if l >= bound {
+			return false
+		}
+		if b.loads[i].CompareAndSwap(l, l+1) {
+			return true
+		}
+	}
+}
+
+// Of a member returned by Acquire.
+func (b *Bounded) Release(member string) {
+	i, ok := b.ring.index[member]
+	if !ok {
+		return
+	}
+	b.loads[i].Add(-1)
+	b.total.Add(-1)
+}
+
+// Current loads by member, intended for metrics.
+func (b *Bounded) Loads() map[string]int64 {
+	m := make(map[string]int64, len(b.loads))
+	for i := range b.loads {
+		m[b.ring.members[i].Name] = b.loads[i].Load()
+	}
+	return m
+}
+
+// Rendezvous (highest random weight) hashing, scoring each member per key. Lookups are
+// O(members) without the ring's memory, and moves only a removed member's keys.
+// Immutable, so concurrent safe.
+type Rendezvous struct {
+	members []Member
+	seeds   []uint64
+}
+
+// Duplicate names are combined, the first weight used.
+func NewRendezvous(members []Member) *Rendezvous {
+	r := &Rendezvous{}
+	seen := make(map[string]struct{}, len(members))
+	for _, m := range members {
+		if _, ok := seen[m.Name]; ok {
+			continue
+		}
+		seen[m.Name] = struct{}{}
+		m.Weight = max(1, m.Weight)
+		r.members = append(r.members, m)
+		r.seeds = append(r.seeds, hashString(m.Name))
+	}
+	return r
+}
+
+func (r *Rendezvous) Members() []Member {
+	return slices.Clone(r.members)
+}
+
+func (r *Rendezvous) Owner(key string) string {
+	h := hashString(key)
+
+	best, bestScore := -1, math.Inf(-1)
+	for i, m := range r.members {
+		// weighted: w / -ln(u) for u uniform in (0,1).
+		u := (float64(mix(h^r.seeds[i])>>11) + 0.5) / (1 << 53)
+		score := float64(m.Weight) / -math.Log(u)
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return r.members[best].Name
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// FNV-1a, mixed as FNV spreads similar short strings poorly. Does not allocate.
+func hashString(s string) uint64 {
+	h := uint64(fnvOffset)
+	for i := range len(s) {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+	return mix(h)
+}
+
+// splitmix64 finalizer.
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,165 @@
+package ring_test
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/graxinc/cache/ring"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRing_Owner(t *testing.T) {
+	t.Parallel()
+
+	diffFatal(t, "", ring.New(nil, ring.Options{}).Owner("a"))
+
+	members := []ring.Member{{Name: "a"}, {Name: "b", Weight: 2}, {Name: "c"}}
+	r := ring.New(members, ring.Options{})
+
+	// independent of member order.
+	r2 := ring.New([]ring.Member{members[2], members[0], members[1]}, ring.Options{})
+
+	counts := map[string]int{}
+	for i := range 40000 {
+		k := strconv.Itoa(i)
+		o := r.Owner(k)
+		diffFatal(t, o, r2.Owner(k))
+		counts[o]++
+	}
+	within(t, 10000, counts["a"], 0.15)
+	within(t, 20000, counts["b"], 0.15)
+	within(t, 10000, counts["c"], 0.15)
+}
+
+func TestRing_Owner_moves(t *testing.T) {
+	t.Parallel()
+
+	r := ring.New([]ring.Member{{Name: "a"}, {Name: "b"}, {Name: "c"}}, ring.Options{})
+	added := ring.New([]ring.Member{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}, ring.Options{})
+
+	var moved int
+	for i := range 10000 {
+		k := strconv.Itoa(i)
+		o, o2 := r.Owner(k), added.Owner(k)
+		if o == o2 {
+			continue
+		}
+		if o2 != "d" {
+			t.Fatal("moved between remaining", k, o, o2)
+		}
+		moved++
+	}
+	within(t, 2500, moved, 0.2)
+}
+
+func TestRing_Owner_allocs(t *testing.T) { //nolint:paralleltest // AllocsPerRun
+	r := ring.New([]ring.Member{{Name: "a"}, {Name: "b"}}, ring.Options{})
+	h := ring.NewRendezvous([]ring.Member{{Name: "a"}, {Name: "b"}})
+	b := ring.NewBounded(r, 0)
+
+	diffFatal(t, 0.0, testing.AllocsPerRun(100, func() { r.Owner("key") }))
+	diffFatal(t, 0.0, testing.AllocsPerRun(100, func() { h.Owner("key") }))
+	diffFatal(t, 0.0, testing.AllocsPerRun(100, func() { b.Release(b.Acquire("key")) }))
+}
+
+func TestBounded(t *testing.T) {
+	t.Parallel()
+
+	r := ring.New([]ring.Member{{Name: "a"}, {Name: "b"}, {Name: "c"}}, ring.Options{VirtualNodes: 10})
+	b := ring.NewBounded(r, 1.25)
+
+	// one hot key spills over once its owner is full.
+	owner := r.Owner("hot")
+	var owners []string
+	for range 30 {
+		owners = append(owners, b.Acquire("hot"))
+	}
+	loads := b.Loads()
+	diffFatal(t, int64(13), loads[owner]) // ceil(1.25 * 30/3)
+	for _, l := range loads {
+		if l > 13 {
+			t.Fatal(loads)
+		}
+	}
+
+	for _, o := range owners {
+		b.Release(o)
+	}
+	diffFatal(t, map[string]int64{"a": 0, "b": 0, "c": 0}, b.Loads())
+
+	diffFatal(t, "", ring.NewBounded(ring.New(nil, ring.Options{}), 0).Acquire("a"))
+}
+
+func TestBounded_concurrent(t *testing.T) {
+	t.Parallel()
+
+	r := ring.New([]ring.Member{{Name: "a"}, {Name: "b", Weight: 3}}, ring.Options{})
+	b := ring.NewBounded(r, 1.5)
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				b.Release(b.Acquire(strconv.Itoa(g*1000 + i)))
+			}
+		}()
+	}
+	wg.Wait()
+	diffFatal(t, map[string]int64{"a": 0, "b": 0}, b.Loads())
+}
+
+func TestRendezvous_Owner(t *testing.T) {
+	t.Parallel()
+
+	diffFatal(t, "", ring.NewRendezvous(nil).Owner("a"))
+
+	r := ring.NewRendezvous([]ring.Member{{Name: "a"}, {Name: "b", Weight: 2}, {Name: "c"}, {Name: "a", Weight: 5}})
+	diffFatal(t, []ring.Member{{Name: "a", Weight: 1}, {Name: "b", Weight: 2}, {Name: "c", Weight: 1}}, r.Members())
+
+	removed := ring.NewRendezvous([]ring.Member{{Name: "a"}, {Name: "b", Weight: 2}})
+
+	counts := map[string]int{}
+	for i := range 40000 {
+		k := strconv.Itoa(i)
+		o := r.Owner(k)
+		counts[o]++
+		if o != "c" {
+			diffFatal(t, o, removed.Owner(k)) // only c's keys move.
+		}
+	}
+	within(t, 10000, counts["a"], 0.1)
+	within(t, 20000, counts["b"], 0.1)
+	within(t, 10000, counts["c"], 0.1)
+}
+
+func BenchmarkRing_Owner(b *testing.B) {
+	var members []ring.Member
+	for i := range 16 {
+		members = append(members, ring.Member{Name: "member" + strconv.Itoa(i)})
+	}
+	r := ring.New(members, ring.Options{})
+
+	b.ResetTimer()
+	for i := range b.N {
+		r.Owner(strconv.Itoa(i & 1023))
+	}
+}
+
+func within(t testing.TB, want, got int, fraction float64) {
+	t.Helper()
+	if math.Abs(float64(got-want)) > fraction*float64(want) {
+		t.Fatalf("want %v within %v, got %v", want, fraction, got)
+	}
+}
+
+func diffFatal(t testing.TB, want, got any, opts ...cmp.Option) {
+	t.Helper()
+	if d := cmp.Diff(want, got, opts...); d != "" {
+		t.Fatalf("(-want +got):\n%v", d)
+	}
+}
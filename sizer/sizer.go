@@ -0,0 +1,116 @@
+// Sizers for cache.CacheOptions.Sizer.
+package sizer
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Length of v.
+func Bytes[K any](_ K, v []byte) int64 {
+	return int64(len(v))
+}
+
+// Length of v.
+func String[K any](_ K, v string) int64 {
+	return int64(len(v))
+}
+
+// Estimated memory of k and v, see Of.
+func Deep[K, V any](k K, v V) int64 {
+	s := newState()
+	return s.of(reflect.ValueOf(&k).Elem()) + s.of(reflect.ValueOf(&v).Elem())
+}
+
+// Estimated memory of v, following pointers, slices, maps, strings and interfaces.
+// Memory reachable multiple times is counted once. Channels and funcs are counted
+// by their header only. Not exact, map and allocator overheads are approximated.
+func Of(v any) int64 {
+	if v == nil {
+		return 0
+	}
+	return newState().of(reflect.ValueOf(v))
+}
+
+// Per map entry, approximating bucket tophash and load factor waste.
+const mapEntryOverhead = 8
+
+type state struct {
+	seen map[uintptr]struct{}
+}
+
+func newState() state {
+	return state{seen: make(map[uintptr]struct{})}
+}
+
+// Includes v itself.
+func (s state) of(v reflect.Value) int64 {
+	return int64(v.Type().Size()) + s.indirect(v)
+}
+
+// Memory referenced by v, excluding v itself.
+func (s state) indirect(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+		return s.of(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		e := v.Elem()
+		if e.Kind() == reflect.Pointer {
+			return s.indirect(e)
+		}
+		return s.of(e) // boxed
+	case reflect.String:
+		if v.Len() == 0 || !s.visit(uintptr(unsafe.Pointer(unsafe.StringData(v.String())))) {
+			return 0
+		}
+		return int64(v.Len())
+	case reflect.Slice:
+		if v.Cap() == 0 || !s.visit(v.Pointer()) {
+			return 0
+		}
+		n := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := range v.Len() {
+			n += s.indirect(v.Index(i))
+		}
+		return n
+	case reflect.Array:
+		var n int64
+		for i := range v.Len() {
+			n += s.indirect(v.Index(i))
+		}
+		return n
+	case reflect.Struct:
+		var n int64
+		for i := range v.NumField() {
+			n += s.indirect(v.Field(i))
+		}
+		return n
+	case reflect.Map:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+		t := v.Type()
+		entry := int64(t.Key().Size()+t.Elem().Size()) + mapEntryOverhead
+		n := int64(v.Len()) * entry
+		for it := v.MapRange(); it.Next(); {
+			n += s.indirect(it.Key()) + s.indirect(it.Value())
+		}
+		return n
+	default:
+		return 0
+	}
+}
+
+func (s state) visit(p uintptr) (first bool) {
+	if _, ok := s.seen[p]; ok {
+		return false
+	}
+	s.seen[p] = struct{}{}
+	return true
+}
@@ -0,0 +1,90 @@
+package sizer_test
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/graxinc/cache/sizer"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBytes(t *testing.T) {
+	t.Parallel()
+
+	diffFatal(t, int64(3), sizer.Bytes(1, []byte("abc")))
+	diffFatal(t, int64(0), sizer.Bytes[int](1, nil))
+}
+
+func TestString(t *testing.T) {
+	t.Parallel()
+
+	diffFatal(t, int64(3), sizer.String(1, "abc"))
+}
+
+func TestOf(t *testing.T) {
+	t.Parallel()
+
+	const ptr = int64(unsafe.Sizeof(uintptr(0)))
+	const str = int64(unsafe.Sizeof(""))
+	const slice = int64(unsafe.Sizeof([]byte{}))
+
+	type inner struct {
+		b []byte
+	}
+	type outer struct {
+		s  string
+		i  *inner
+		i2 *inner // same pointer, counted once.
+		a  any
+	}
+
+	in := &inner{b: make([]byte, 3, 10)}
+	v := outer{s: "hello", i: in, i2: in, a: 5}
+
+	want := str + 2*ptr + 2*ptr + // outer
+		5 + // s
+		slice + 10 + // *inner
+		8 // boxed int
+	diffFatal(t, want, sizer.Of(v))
+
+	diffFatal(t, int64(0), sizer.Of(nil))
+}
+
+func TestOf_cycle(t *testing.T) {
+	t.Parallel()
+
+	type node struct {
+		next *node
+	}
+	n := &node{}
+	n.next = n
+
+	const ptr = int64(unsafe.Sizeof(uintptr(0)))
+	diffFatal(t, 2*ptr, sizer.Of(n))
+}
+
+func TestOf_map(t *testing.T) {
+	t.Parallel()
+
+	m := map[int64]string{1: "a", 2: "bc"}
+
+	const ptr = int64(unsafe.Sizeof(uintptr(0)))
+	const str = int64(unsafe.Sizeof(""))
+	want := ptr + 2*(8+str+8) + 3
+	diffFatal(t, want, sizer.Of(m))
+}
+
+func TestDeep(t *testing.T) {
+	t.Parallel()
+
+	const str = int64(unsafe.Sizeof(""))
+	diffFatal(t, 2*str+5, sizer.Deep("ab", "cde"))
+}
+
+func diffFatal(t testing.TB, want, got any, opts ...cmp.Option) {
+	t.Helper()
+	if d := cmp.Diff(want, got, opts...); d != "" {
+		t.Fatalf("(-want +got):\n%v", d)
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Tagged values by tag, see Cache.SetST. Entries are removed with their values.
+// Concurrent safe.
+type tagIndex[K, V any] struct {
+	mu     sync.Mutex
+	tags   map[string]map[*CacheValue[V]]K
+	values map[*CacheValue[V]][]string
+	length atomic.Int64 // of values, skipping the lock when none.
+}
+
+func (t *tagIndex[K, V]) add(k K, v *CacheValue[V], tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tags == nil {
+		t.tags = make(map[string]map[*CacheValue[V]]K)
+		t.values = make(map[*CacheValue[V]][]string)
+	}
+	for _, tag := range tags {
+		vs := t.tags[tag]
+		if vs == nil {
+			vs = make(map[*CacheValue[V]]K)
+			t.tags[tag] = vs
+		}
+		vs[v] = k
+	}
+	t.values[v] = slices.Clone(tags)
+	t.length.Add(1)
+}
+
+func (t *tagIndex[K, V]) remove(v *CacheValue[V]) {
+	if t.length.Load() == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tags, ok := t.values[v]
+	if !ok {
+		return
+	}
+	for _, tag := range tags {
+		vs := t.tags[tag]
+		delete(vs, v)
+		if len(vs) == 0 {
+			delete(t.tags, tag)
+		}
+	}
+	delete(t.values, v)
+	t.length.Add(-1)
+}
+
+// Entries remain until their values are removed.
+func (t *tagIndex[K, V]) entries(tag string) []entry[K, V] {
+	if t.length.Load() == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	vs := t.tags[tag]
+	es := make([]entry[K, V], 0, len(vs))
+	for v, k := range vs {
+		es = append(es, entry[K, V]{k, v})
+	}
+	return es
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Expiration and size packed into a word, keeping CacheValue small.
+type valueMeta uint64
+
+const (
+	metaExpireBits = 64 - packedBits
+	metaExpireMax  = 1<<metaExpireBits - 1 // ~34 years of milliseconds.
+)
+
+// expire in milliseconds since expirationEpoch, 0 for never. Larger saturates.
+func newValueMeta(expire int64, size packedSize) valueMeta {
+	expire = min(max(expire, 0), metaExpireMax)
+	return valueMeta(uint64(expire)<<packedBits | uint64(size))
+}
+
+func (m valueMeta) expire() int64 {
+	return int64(m >> packedBits)
+}
+
+func (m valueMeta) size() packedSize {
+	return packedSize(m & (1<<packedBits - 1))
+}
+
+// A size packed into 24 bits, see valueMeta.
+// Exact below 2^23, otherwise a float-like exponent and mantissa rounded up
+// with a relative error under 2^-18, saturating at ~2^55.
+type packedSize uint32
+
+const (
+	packedBits     = 24
+	packedFlag     = 1 << (packedBits - 1)
+	packedExpBits  = 5
+	packedMantBits = packedBits - 1 - packedExpBits
+	packedShift    = packedBits - 1 - packedMantBits // min shift of flagged sizes.
+	packedMax      = packedFlag | (1<<(packedBits-1) - 1)
+)
+
+// Negative sizes pack as 0.
+func packSize(s int64) packedSize {
+	if s < packedFlag {
+		return packedSize(max(s, 0))
+	}
+
+	u := uint64(s)
+	shift := bits.Len64(u) - (packedMantBits + 1)
+	m := (u + (1 << shift) - 1) >> shift // round up
+	if m == 1<<(packedMantBits+1) {      // rounding overflowed the mantissa
+		m >>= 1
+		shift++
+	}
+
+	e := shift - packedShift
+	if e >= 1<<packedExpBits {
+		return packedMax
+	}
+	return packedSize(packedFlag | uint32(e)<<packedMantBits | uint32(m-1<<packedMantBits))
+}
+
+func (p packedSize) int64() int64 {
+	if p&packedFlag == 0 {
+		return int64(p)
+	}
+	e := int(p>>packedMantBits) & (1<<packedExpBits - 1)
+	m := uint64(p)&(1<<packedMantBits-1) | 1<<packedMantBits
+	v := m << (e + packedShift)
+	return int64(min(v, math.MaxInt64))
+}
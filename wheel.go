@@ -0,0 +1,113 @@
+package cache
+
+// Hierarchical timing wheel of expirations, in milliseconds since expirationEpoch.
+// Level i slots span 64^i milliseconds, the top level covering all of valueMeta.
+// Entries are removed with their values, see remove. Touched values are
+// rescheduled once their slot is reached.
+// Not concurrent safe.
+type wheel[K, V any] struct {
+	now   int64 // advanced through.
+	slots [wheelLevels][wheelSlots][]entry[K, V]
+	due   []entry[K, V]                     // scheduled at or before now.
+	at    map[*CacheValue[V]]wheelPos[K, V] // of each entry, for remove.
+}
+
+// An entry's index within a slot or due.
+type wheelPos[K, V any] struct {
+	list *[]entry[K, V]
+	i    int
+}
+
+const (
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelLevels = (metaExpireBits + wheelBits - 1) / wheelBits
+)
+
+// expire as in valueMeta, 0 is not scheduled. Replaces v's entry.
+func (w *wheel[K, V]) schedule(k K, v *CacheValue[V], expire int64) {
+	if expire == 0 {
+		return
+	}
+	w.remove(v)
+	if w.at == nil {
+		w.at = make(map[*CacheValue[V]]wheelPos[K, V])
+	}
+
+	list := &w.due
+	if expire > w.now {
+		// lowest level sharing the next level's tick with now, so the slot is
+		// reached before the level wraps.
+		l := 0
+		for l < wheelLevels-1 && expire>>((l+1)*wheelBits) != w.now>>((l+1)*wheelBits) {
+			l++
+		}
+		list = &w.slots[l][(expire>>(l*wheelBits))&(wheelSlots-1)]
+	}
+	*list = append(*list, entry[K, V]{k, v})
+	w.at[v] = wheelPos[K, V]{list, len(*list) - 1}
+}
+
+// Drops v's entry, moving the last of its list into its place.
+func (w *wheel[K, V]) remove(v *CacheValue[V]) {
+	p, ok := w.at[v]
+	if !ok {
+		return
+	}
+	delete(w.at, v)
+
+	l := *p.list
+	last := len(l) - 1
+	if p.i != last {
+		l[p.i] = l[last]
+		w.at[l[p.i].v] = p
+	}
+	l[last] = entry[K, V]{} // not holding the value.
+	*p.list = l[:last]
+}
+
+// Returns entries expiring at or before to, which are no longer scheduled.
+// Entries touched since scheduling are rescheduled.
+func (w *wheel[K, V]) advance(to int64) (due []entry[K, V]) {
+	if to < w.now {
+		return nil
+	}
+	from := w.now
+	w.now = to
+
+	pending := w.due
+	w.due = nil
+	for l := range wheelLevels {
+		shift := l * wheelBits
+		fromTick, toTick := from>>shift, to>>shift
+		if fromTick == toTick {
+			break // higher levels share the tick too.
+		}
+		ticks := min(toTick-fromTick, wheelSlots)
+		for t := fromTick + 1; t <= fromTick+ticks; t++ {
+			s := &w.slots[l][t&(wheelSlots-1)]
+			pending = append(pending, *s...)
+			clear(*s)
+			*s = (*s)[:0]
+		}
+	}
+
+	for _, e := range pending {
+		delete(w.at, e.v)
+		expire := e.v.loadMeta().expire()
+		if expire > to {
+			w.schedule(e.k, e.v, expire) // touched, or a higher level's slot.
+			continue
+		}
+		due = append(due, e)
+	}
+	return due
+}
+
+func (w *wheel[K, V]) len() int {
+	return len(w.at)
+}
+
+func (w *wheel[K, V]) clear() {
+	*w = wheel[K, V]{now: w.now}
+}